@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -8,53 +9,59 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
+	"time"
 
 	"github.com/evanwiseman/httpfromtcp/internal/headers"
+	"github.com/evanwiseman/httpfromtcp/internal/middleware"
 	"github.com/evanwiseman/httpfromtcp/internal/request"
 	"github.com/evanwiseman/httpfromtcp/internal/response"
+	"github.com/evanwiseman/httpfromtcp/internal/router"
 	"github.com/evanwiseman/httpfromtcp/internal/server"
 )
 
 const port = 42069
 
+// shutdownTimeout is how long in-flight requests get to finish on shutdown.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
+	handler := server.Wrap(newRouter().Handler,
+		middleware.Logger,
+		middleware.Recover,
+		middleware.RequestID,
+		middleware.Timing,
+	)
 	server, err := server.Serve(port, handler)
 	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
-	defer server.Close()
 	log.Println("Server started on port", port)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
-	log.Println("Server gracefully stopped")
-}
 
-func handler(w *response.Writer, r *request.Request) {
-	target := strings.TrimSpace(r.RequestLine.RequestTarget)
-	if r.RequestLine.RequestTarget == "/myproblem" {
-		handler400(w, r)
-		return
-	}
-	if r.RequestLine.RequestTarget == "/myproblem" {
-		handler500(w, r)
-		return
-	}
-	if strings.HasPrefix(target, "/httpbin") {
-		handlerHttpbin(w, r)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if cut, err := server.Shutdown(ctx); err != nil {
+		log.Printf("Server stopped, %d connections cut: %v", cut, err)
 		return
 	}
-	if r.RequestLine.RequestTarget == "/video" {
-		handlerVideo(w, r)
-		return
-	}
-	handler200(w, r)
+	log.Println("Server gracefully stopped")
+}
 
+func newRouter() *router.Router {
+	rt := router.New()
+	rt.Handle("GET /yourproblem", handler400)
+	rt.Handle("GET /myproblem", handler500)
+	rt.Handle("GET /httpbin/{path...}", handlerHttpbin)
+	rt.Handle("GET /video", handlerVideo)
+	rt.Handle("/{path...}", handler200)
+	return rt
 }
 
 func handler200(w *response.Writer, _ *request.Request) {
@@ -68,8 +75,7 @@ func handler200(w *response.Writer, _ *request.Request) {
 <p>Your request was an absolute banger.</p>
 </body>
 </html>`)
-	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
-	w.WriteBody(body)
+	w.Write(body)
 }
 
 func handler400(w *response.Writer, _ *request.Request) {
@@ -83,8 +89,7 @@ func handler400(w *response.Writer, _ *request.Request) {
 <p>Your request honestly kinda sucked.</p>
 </body>
 </html>`)
-	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
-	w.WriteBody(body)
+	w.Write(body)
 }
 
 func handler500(w *response.Writer, _ *request.Request) {
@@ -98,51 +103,58 @@ func handler500(w *response.Writer, _ *request.Request) {
 <p>Okay, you know what? This one is on me.</p>
 </body>
 </html>`)
-	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
-	w.WriteBody(body)
+	w.Write(body)
 }
 
 func handlerHttpbin(w *response.Writer, r *request.Request) {
 	// Get the target
-	endpoint := strings.TrimPrefix(r.RequestLine.RequestTarget, "/httpbin")
-	url := "https://httpbin.org" + endpoint
+	target := url.URL{
+		Scheme:   "https",
+		Host:     "httpbin.org",
+		Path:     "/" + r.Param("path"),
+		RawQuery: r.Target.RawQuery,
+	}
+	endpoint := target.String()
 
-	// Get the response from the url
-	res, err := http.Get(url)
+	// Get the response from the url, giving up once the client goes away
+	upstream, err := http.NewRequestWithContext(r.Context(), http.MethodGet, endpoint, nil)
+	if err != nil {
+		log.Printf("Failed to build request for %s: %v", endpoint, err)
+		handler400(w, r)
+		return
+	}
+	res, err := http.DefaultClient.Do(upstream)
 	if err != nil {
-		log.Printf("Failed to fetch from %s: %v", url, err)
+		log.Printf("Failed to fetch from %s: %v", endpoint, err)
 		handler400(w, r)
 		return
 	}
 	defer res.Body.Close()
 
-	// Remove for chunked encoding
-	res.Header.Del("content-length")
-
-	header := headers.NewHeaders()
-	header.Set("Transfer-Encoding", "Chunked")
-	header.Set("Content-Type", res.Header.Get("Content-Type"))
-	header.Set("Trailer", "X-Content-SHA256, X-Content-Length")
+	h := w.Header()
+	h.Set("Content-Type", res.Header.Get("Content-Type"))
+	h.Set("Trailer", "X-Content-SHA256, X-Content-Length")
 	w.WriteStatusLine(response.StatusOk)
-	w.WriteHeaders(header)
-	log.Print(header)
 	length := 0
 	buf := make([]byte, 1024)
 
 	var body []byte
 	for {
 		n, err := res.Body.Read(buf)
+		if n > 0 {
+			length += n
+			body = append(body, buf[:n]...)
+			w.Write(buf[:n])
+			if err := w.Flush(); err != nil {
+				log.Printf("Failed to stream response from %s: %v", endpoint, err)
+				return
+			}
+		}
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
-			handler400(w, r)
-			return
-		}
-		length += n
-		body = append(body, buf[:n]...)
-		if _, err := w.WriteChunkedBody(buf[:n]); err != nil {
-			handler400(w, r)
+			log.Printf("Failed to read from %s: %v", endpoint, err)
 			return
 		}
 	}
@@ -164,10 +176,8 @@ func handlerVideo(w *response.Writer, r *request.Request) {
 	}
 
 	w.WriteStatusLine(response.StatusOk)
-	header := headers.NewHeaders()
-	header.Set("Content-Type", "video/mp4")
-	header.Set("Content-Length", fmt.Sprintf("%d", len(videoBytes)))
-	header.Set("Connection", "close")
-	w.WriteHeaders(header)
-	w.WriteBody(videoBytes)
+	h := w.Header()
+	h.Set("Content-Type", "video/mp4")
+	h.Set("Content-Length", fmt.Sprintf("%d", len(videoBytes)))
+	w.Write(videoBytes)
 }
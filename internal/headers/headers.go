@@ -2,27 +2,203 @@ package headers
 
 import (
 	"fmt"
+	"iter"
 	"strings"
 )
 
 const crlf = "\r\n"
 
-type Headers map[string]string
+// Error is an error met while parsing a message. Status is the HTTP status
+// code a server should answer with, and Message is a short description that
+// is safe to send to the client. The request package uses it for its own
+// parse errors as well.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// StatusCode returns the HTTP status code for the error.
+func (e *Error) StatusCode() int {
+	return e.Status
+}
+
+var (
+	ErrMalformedField   = &Error{Status: 400, Message: "malformed header field"}
+	ErrInvalidFieldName = &Error{Status: 400, Message: "invalid header field name"}
+)
+
+// Headers is an ordered list of header fields. Each field keeps the casing
+// of the name it was first added with and all of its values in the order
+// they were added. Lookups are case-insensitive.
+type Headers struct {
+	fields []field
+}
+
+type field struct {
+	name   string
+	values []string
+}
+
+func NewHeaders() *Headers {
+	return &Headers{}
+}
+
+func (h *Headers) index(name string) int {
+	for i, f := range h.fields {
+		if strings.EqualFold(f.name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the values of the named field joined with ", ", and whether the
+// field is present. Use Values for fields such as Set-Cookie whose values
+// cannot be combined.
+func (h *Headers) Get(name string) (value string, ok bool) {
+	i := h.index(name)
+	if i == -1 {
+		return "", false
+	}
+	return strings.Join(h.fields[i].values, ", "), true
+}
 
-func NewHeaders() Headers {
-	return make(Headers)
+// Values returns every value of the named field in the order they were
+// added, or nil if the field is not present.
+func (h *Headers) Values(name string) []string {
+	i := h.index(name)
+	if i == -1 {
+		return nil
+	}
+	return h.fields[i].values
 }
 
-func (h Headers) Get(key string) (value string, ok bool) {
-	value, ok = h[strings.ToLower(key)]
-	return value, ok
+// Add appends value to the named field, adding the field at the end if it
+// is not present yet.
+func (h *Headers) Add(name string, value string) {
+	i := h.index(name)
+	if i == -1 {
+		h.fields = append(h.fields, field{name: name, values: []string{value}})
+		return
+	}
+	h.fields[i].values = append(h.fields[i].values, value)
 }
 
-func (h *Headers) Set(key string, value string) {
-	(*h)[strings.ToLower(key)] = value
+// Set replaces all values of the named field with value. An existing field
+// keeps its position, otherwise the field is added at the end.
+func (h *Headers) Set(name string, value string) {
+	i := h.index(name)
+	if i == -1 {
+		h.fields = append(h.fields, field{name: name, values: []string{value}})
+		return
+	}
+	h.fields[i].values = []string{value}
 }
 
-func (h Headers) Parse(data []byte) (n int, done bool, err error) {
+// Del removes the named field.
+func (h *Headers) Del(name string) {
+	i := h.index(name)
+	if i == -1 {
+		return
+	}
+	h.fields = append(h.fields[:i], h.fields[i+1:]...)
+}
+
+// Len returns the number of distinct fields.
+func (h *Headers) Len() int {
+	return len(h.fields)
+}
+
+// Names returns the field names in insertion order, with their original
+// casing.
+func (h *Headers) Names() []string {
+	names := make([]string, len(h.fields))
+	for i, f := range h.fields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// All iterates over every (name, value) pair in insertion order, yielding a
+// field once per value. Names are yielded with their original casing.
+func (h *Headers) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, f := range h.fields {
+			for _, v := range f.values {
+				if !yield(f.name, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// CanonicalName returns name with the first letter and each letter after a
+// hyphen upper-cased and the rest lower-cased, e.g. "Content-Type".
+func CanonicalName(name string) string {
+	b := []byte(name)
+	upper := true
+	for i, c := range b {
+		switch {
+		case upper && 'a' <= c && c <= 'z':
+			b[i] = c - 'a' + 'A'
+		case !upper && 'A' <= c && c <= 'Z':
+			b[i] = c - 'A' + 'a'
+		}
+		upper = c == '-'
+	}
+	return string(b)
+}
+
+func (h *Headers) String() string {
+	var sb strings.Builder
+	for name, value := range h.All() {
+		sb.WriteString(CanonicalName(name) + ": " + value + crlf)
+	}
+	return sb.String()
+}
+
+// HasToken reports whether the comma-separated list stored under key contains
+// token, compared case-insensitively.
+func (h *Headers) HasToken(key string, token string) bool {
+	for _, value := range h.Values(key) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidName reports whether name is a valid field name, a non-empty token.
+func ValidName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for _, c := range name {
+		if !isTokenChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidValue reports whether value can be written as a field value without
+// changing the message framing, that is it contains no CR, LF or NUL.
+func ValidValue(value string) bool {
+	return !strings.ContainsAny(value, "\r\n\x00")
+}
+
+func isTokenChar(c rune) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", c)
+}
+
+func (h *Headers) Parse(data []byte) (n int, done bool, err error) {
 	text := string(data)
 	crlfIdx := strings.Index(text, crlf)
 	if crlfIdx == -1 {
@@ -36,29 +212,32 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 		return n, true, nil
 	}
 
+	// A line starting with whitespace is an obsolete folded continuation of
+	// the previous field, or hides the first field from parsers that ignore
+	// it; either way it must be rejected
+	if line[0] == ' ' || line[0] == '\t' {
+		return 0, false, fmt.Errorf("%w: obsolete line folding", ErrMalformedField)
+	}
+
 	colonIdx := strings.Index(line, ":")
 	if colonIdx == -1 {
-		return 0, false, fmt.Errorf("error: invalid header")
+		return 0, false, fmt.Errorf("%w: missing colon", ErrMalformedField)
 	}
 	fieldName := line[:colonIdx]
 	fieldValue := line[colonIdx+1:]
 	if len(fieldName) == 0 || fieldName[len(fieldName)-1] == ' ' || fieldName[len(fieldName)-1] == '\t' {
-		return 0, false, fmt.Errorf("error: invalid field name")
+		return 0, false, fmt.Errorf("%w: whitespace before colon", ErrMalformedField)
 	}
 
-	name := strings.ToLower(strings.TrimSpace(fieldName))
+	name := strings.TrimSpace(fieldName)
 	for _, c := range name {
-		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.Contains("!#$%&'*+-.^_`|~", string(c))) {
-			return 0, false, fmt.Errorf("error: invalid character in field name: %v", string(c))
+		if !isTokenChar(c) {
+			return 0, false, fmt.Errorf("%w: invalid character in field name: %v", ErrInvalidFieldName, string(c))
 		}
 	}
 	value := strings.TrimSpace(fieldValue)
 
-	if _, ok := h[strings.ToLower(name)]; ok {
-		h[name] = h[name] + ", " + value
-	} else {
-		h[name] = value
-	}
+	h.Add(name, value)
 
 	return n, false, nil
 }
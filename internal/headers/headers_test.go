@@ -14,18 +14,26 @@ func TestHeadersParse(t *testing.T) {
 	n, done, err := headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:42069", headers["host"])
+	assert.Equal(t, []string{"localhost:42069"}, headers.Values("host"))
 	assert.Equal(t, 23, n)
 	assert.False(t, done)
 
-	// Test: Valid single header with extra space
+	// Test: Valid single header with trailing space
 	headers = NewHeaders()
-	data = []byte("     Host: localhost:42069    \r\n\r\n")
+	data = []byte("Host: localhost:42069    \r\n\r\n")
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:42069", headers["host"])
-	assert.Equal(t, 32, n)
+	assert.Equal(t, []string{"localhost:42069"}, headers.Values("host"))
+	assert.Equal(t, 27, n)
+	assert.False(t, done)
+
+	// Test: Leading whitespace on the first field line
+	headers = NewHeaders()
+	data = []byte("     Host: localhost:42069    \r\n\r\n")
+	n, done, err = headers.Parse(data)
+	require.ErrorIs(t, err, ErrMalformedField)
+	assert.Equal(t, 0, n)
 	assert.False(t, done)
 
 	// Test: Valid 2 headers with existing
@@ -34,7 +42,7 @@ func TestHeadersParse(t *testing.T) {
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:42069", headers["host"])
+	assert.Equal(t, []string{"localhost:42069"}, headers.Values("host"))
 	assert.Equal(t, 23, n)
 	assert.False(t, done)
 
@@ -42,7 +50,7 @@ func TestHeadersParse(t *testing.T) {
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "curl/8.6.0", headers["user-agent"])
+	assert.Equal(t, []string{"curl/8.6.0"}, headers.Values("user-agent"))
 	assert.Equal(t, 24, n)
 	assert.False(t, done)
 
@@ -69,7 +77,10 @@ func TestHeadersParse(t *testing.T) {
 	_, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "lane-loves-go, prime-loves-zig, tj-loves-ocaml", headers["set-person"])
+	value, ok := headers.Get("set-person")
+	assert.True(t, ok)
+	assert.Equal(t, "lane-loves-go, prime-loves-zig, tj-loves-ocaml", value)
+	assert.Equal(t, []string{"lane-loves-go", "prime-loves-zig", "tj-loves-ocaml"}, headers.Values("Set-Person"))
 	assert.False(t, done)
 
 	// Test: Invalid character in field name
@@ -87,4 +98,56 @@ func TestHeadersParse(t *testing.T) {
 	require.Error(t, err)
 	assert.Equal(t, 0, n)
 	assert.False(t, done)
+
+	// Test: Obsolete line folding
+	headers = NewHeaders()
+	data = []byte("X-Folded: first\r\n second: part\r\n\r\n")
+	n, _, err = headers.Parse(data)
+	require.NoError(t, err)
+	n, done, err = headers.Parse(data[n:])
+	require.ErrorIs(t, err, ErrMalformedField)
+	assert.Equal(t, 0, n)
+	assert.False(t, done)
+}
+
+func TestHeadersOrderedValues(t *testing.T) {
+	// Test: Insertion order, original casing and repeated fields
+	headers := NewHeaders()
+	headers.Add("Set-Cookie", "a=1")
+	headers.Add("content-TYPE", "text/plain")
+	headers.Add("set-cookie", "b=2")
+	var names, values []string
+	for name, value := range headers.All() {
+		names = append(names, name)
+		values = append(values, value)
+	}
+	assert.Equal(t, []string{"Set-Cookie", "Set-Cookie", "content-TYPE"}, names)
+	assert.Equal(t, []string{"a=1", "b=2", "text/plain"}, values)
+	assert.Equal(t, []string{"a=1", "b=2"}, headers.Values("SET-COOKIE"))
+	assert.Equal(t, 2, headers.Len())
+
+	// Test: Set replaces values in place
+	headers.Set("Set-Cookie", "c=3")
+	assert.Equal(t, []string{"c=3"}, headers.Values("set-cookie"))
+	assert.Equal(t, "Set-Cookie: c=3\r\nContent-Type: text/plain\r\n", headers.String())
+
+	// Test: Del removes the field
+	headers.Del("SET-COOKIE")
+	_, ok := headers.Get("set-cookie")
+	assert.False(t, ok)
+	assert.Nil(t, headers.Values("set-cookie"))
+	assert.Equal(t, 1, headers.Len())
+
+	// Test: Parse keeps the wire casing
+	headers = NewHeaders()
+	_, _, err := headers.Parse([]byte("X-Custom-ID: 7\r\n"))
+	require.NoError(t, err)
+	for name := range headers.All() {
+		assert.Equal(t, "X-Custom-ID", name)
+	}
+
+	// Test: Canonical names
+	assert.Equal(t, "Content-Type", CanonicalName("content-type"))
+	assert.Equal(t, "X-Request-Id", CanonicalName("X-REQUEST-ID"))
+	assert.Equal(t, "Www-Authenticate", CanonicalName("www-authenticate"))
 }
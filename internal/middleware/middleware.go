@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"github.com/evanwiseman/httpfromtcp/internal/headers"
+	"github.com/evanwiseman/httpfromtcp/internal/request"
+	"github.com/evanwiseman/httpfromtcp/internal/response"
+	"github.com/evanwiseman/httpfromtcp/internal/server"
+)
+
+// RequestIDHeader carries the request ID on both the request and response.
+const RequestIDHeader = "X-Request-ID"
+
+// Recover recovers from panics in the wrapped handler the same way the server
+// does, see server.HandlePanic, answering with server.DefaultErrorHandler.
+// Unlike a panic reaching the server, a panic recovered before the status
+// line was written leaves the connection open for the next request.
+func Recover(next server.Handler) server.Handler {
+	return func(w *response.Writer, req *request.Request) {
+		defer func() {
+			if v := recover(); v != nil {
+				server.HandlePanic(w, req, v, debug.Stack(), server.DefaultErrorHandler)
+			}
+		}()
+		next(w, req)
+	}
+}
+
+// Logger logs the method, target, status, body size and duration of every
+// request once its response is finished, so that a body written with Write
+// and committed only by Finish is accounted for.
+func Logger(next server.Handler) server.Handler {
+	return func(w *response.Writer, req *request.Request) {
+		start := time.Now()
+		w.OnFinish(func() {
+			log.Printf("%s %s %d %dB %v", req.RequestLine.Method, req.RequestLine.RequestTarget, w.StatusCode(), w.BodyBytes(), time.Since(start))
+		})
+		next(w, req)
+	}
+}
+
+// requestIDKey is the request context key for the request ID.
+type requestIDKey struct{}
+
+// RequestID makes sure every request carries an X-Request-ID header,
+// generating one if the client did not send it, and echoes it on the
+// response. The ID is also stored in the request context, see GetRequestID.
+func RequestID(next server.Handler) server.Handler {
+	return func(w *response.Writer, req *request.Request) {
+		id, ok := req.Headers.Get(RequestIDHeader)
+		if !ok || id == "" {
+			id = newRequestID()
+			req.Headers.Set(RequestIDHeader, id)
+		}
+		req.SetValue(requestIDKey{}, id)
+		w.OnWriteHeaders(func(h *headers.Headers) {
+			h.Set(RequestIDHeader, id)
+		})
+		next(w, req)
+	}
+}
+
+// GetRequestID returns the ID RequestID assigned to req, or "" if the request
+// did not pass through RequestID.
+func GetRequestID(req *request.Request) string {
+	id, _ := req.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Timing adds an X-Response-Time header with the time the handler took to
+// produce its response headers.
+func Timing(next server.Handler) server.Handler {
+	return func(w *response.Writer, req *request.Request) {
+		start := time.Now()
+		w.OnWriteHeaders(func(h *headers.Headers) {
+			h.Set("X-Response-Time", fmt.Sprint(time.Since(start)))
+		})
+		next(w, req)
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/evanwiseman/httpfromtcp/internal/headers"
+	"github.com/evanwiseman/httpfromtcp/internal/request"
+	"github.com/evanwiseman/httpfromtcp/internal/response"
+	"github.com/evanwiseman/httpfromtcp/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func okHandler(w *response.Writer, _ *request.Request) {
+	body := []byte("ok")
+	w.WriteStatusLine(response.StatusOk)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
+
+func serve(t *testing.T, h server.Handler, req *request.Request) (*response.Writer, *http.Response, string) {
+	t.Helper()
+	if req == nil {
+		req = &request.Request{
+			RequestLine: request.RequestLine{Method: "GET", RequestTarget: "/", HttpVersion: "1.1"},
+			Headers:     headers.NewHeaders(),
+		}
+	}
+	var buf bytes.Buffer
+	w := response.NewWriter(&buf)
+	h(w, req)
+	require.NoError(t, w.Finish())
+
+	res, err := http.ReadResponse(bufio.NewReader(&buf), nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return w, res, string(body)
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	trace := func(name string) server.Middleware {
+		return func(next server.Handler) server.Handler {
+			return func(w *response.Writer, req *request.Request) {
+				order = append(order, name)
+				next(w, req)
+			}
+		}
+	}
+
+	// Test: First middleware is outermost
+	h := server.Wrap(okHandler, trace("a"), trace("b"), server.Chain(trace("c"), trace("d")))
+	serve(t, h, nil)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, order)
+}
+
+func TestRecover(t *testing.T) {
+	// Test: Panic before the status line becomes a 500
+	h := Recover(func(w *response.Writer, req *request.Request) {
+		panic("boom")
+	})
+	w, res, _ := serve(t, h, nil)
+	assert.Equal(t, 500, res.StatusCode)
+	assert.False(t, w.ClosesConnection())
+
+	// Test: Body buffered before the panic is dropped
+	h = Recover(func(w *response.Writer, req *request.Request) {
+		fmt.Fprint(w, "partial")
+		panic("boom")
+	})
+	_, res, body := serve(t, h, nil)
+	assert.Equal(t, 500, res.StatusCode)
+	assert.NotContains(t, body, "partial")
+
+	// Test: Panic mid-response closes the connection
+	h = Recover(func(w *response.Writer, req *request.Request) {
+		okHandler(w, req)
+		panic("boom")
+	})
+	w, res, body = serve(t, h, nil)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "ok", body)
+	assert.True(t, w.ClosesConnection())
+}
+
+func TestRequestID(t *testing.T) {
+	// Test: ID is generated and echoed
+	var seen string
+	h := RequestID(func(w *response.Writer, req *request.Request) {
+		seen, _ = req.Headers.Get(RequestIDHeader)
+		okHandler(w, req)
+	})
+	_, res, _ := serve(t, h, nil)
+	assert.Len(t, seen, 32)
+	assert.Equal(t, seen, res.Header.Get(RequestIDHeader))
+
+	// Test: Client ID is kept
+	req := &request.Request{Headers: headers.NewHeaders()}
+	req.Headers.Set(RequestIDHeader, "abc")
+	_, res, _ = serve(t, h, req)
+	assert.Equal(t, "abc", seen)
+	assert.Equal(t, "abc", res.Header.Get(RequestIDHeader))
+
+	// Test: ID is stored in the request context
+	var fromContext string
+	h = RequestID(func(w *response.Writer, req *request.Request) {
+		fromContext = GetRequestID(req)
+		okHandler(w, req)
+	})
+	_, res, _ = serve(t, h, nil)
+	assert.Equal(t, res.Header.Get(RequestIDHeader), fromContext)
+	assert.Empty(t, GetRequestID(&request.Request{}))
+}
+
+func TestContextValues(t *testing.T) {
+	type userKey struct{}
+
+	// Test: Values set by the handler are visible to outer middleware
+	var user any
+	outer := func(next server.Handler) server.Handler {
+		return func(w *response.Writer, req *request.Request) {
+			next(w, req)
+			user = req.Value(userKey{})
+		}
+	}
+	h := server.Wrap(func(w *response.Writer, req *request.Request) {
+		req.SetValue(userKey{}, "lane")
+		okHandler(w, req)
+	}, outer, RequestID)
+	serve(t, h, nil)
+	assert.Equal(t, "lane", user)
+}
+
+func TestLoggerAndTiming(t *testing.T) {
+	// Test: Handler output is unchanged and timing header is added
+	w, res, body := serve(t, server.Wrap(okHandler, Logger, Timing), nil)
+	assert.Equal(t, "ok", body)
+	assert.NotEmpty(t, res.Header.Get("X-Response-Time"))
+	assert.Equal(t, response.StatusCode(200), w.StatusCode())
+	assert.Equal(t, 2, w.BodyBytes())
+
+	// Test: Body written with Write is logged once the response is finished
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	h := Logger(func(w *response.Writer, req *request.Request) {
+		fmt.Fprint(w, "hello")
+	})
+	_, res, body = serve(t, h, nil)
+	assert.Equal(t, "hello", body)
+	assert.Contains(t, logs.String(), "GET / 200 5B")
+}
@@ -0,0 +1,73 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// bodyReader streams a request body from the connection, running the bytes
+// it reads through the request's parser to strip the message framing.
+type bodyReader struct {
+	req    *Request
+	src    io.Reader
+	buf    []byte // bytes read from src but not yet parsed
+	err    error
+	closed bool
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, fmt.Errorf("error: read on closed body")
+	}
+
+	for len(b.req.pending) == 0 && b.req.State != ParserDone {
+		// Parse whatever has already been read
+		n, err := b.req.parse(b.buf)
+		if err != nil {
+			b.err = err
+			return 0, err
+		}
+		b.buf = b.buf[n:]
+		if len(b.req.pending) > 0 || b.req.State == ParserDone {
+			break
+		}
+		if n > 0 {
+			continue
+		}
+
+		// Need more data
+		if b.err != nil {
+			return 0, b.err
+		}
+		chunk := make([]byte, max(len(p), bufferSize))
+		numBytesRead, err := b.src.Read(chunk)
+		b.buf = append(b.buf, chunk[:numBytesRead]...)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = fmt.Errorf("%w: %w", ErrIncompleteRequest, io.ErrUnexpectedEOF)
+			}
+			b.err = err
+		}
+	}
+
+	if b.req.State == ParserDone && len(b.buf) > 0 {
+		// Bytes past the end of the body belong to the next request
+		unread(b.src, b.buf)
+		b.buf = nil
+	}
+
+	if len(b.req.pending) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, b.req.pending)
+	b.req.pending = b.req.pending[n:]
+	return n, nil
+}
+
+// Close stops further reads from the body. Unread body bytes are left on the
+// connection.
+func (b *bodyReader) Close() error {
+	b.closed = true
+	return nil
+}
@@ -0,0 +1,25 @@
+package request
+
+import "github.com/evanwiseman/httpfromtcp/internal/headers"
+
+// Error is a request parsing error, carrying the status code to answer with.
+// Parse errors wrap one of the Err values below with details about the
+// offending input, which are meant for logs only.
+type Error = headers.Error
+
+var (
+	ErrMalformedRequestLine      = &Error{Status: 400, Message: "malformed request-line"}
+	ErrInvalidMethod             = &Error{Status: 400, Message: "invalid method"}
+	ErrInvalidTarget             = &Error{Status: 400, Message: "invalid request-target"}
+	ErrInvalidHost               = &Error{Status: 400, Message: "invalid host"}
+	ErrInvalidContentLength      = &Error{Status: 400, Message: "invalid content-length"}
+	ErrMalformedChunk            = &Error{Status: 400, Message: "malformed chunked body"}
+	ErrAmbiguousFraming          = &Error{Status: 400, Message: "ambiguous message framing"}
+	ErrIncompleteRequest         = &Error{Status: 400, Message: "incomplete request"}
+	ErrBodyTooLarge              = &Error{Status: 413, Message: "request body too large"}
+	ErrRequestLineTooLong        = &Error{Status: 414, Message: "request-line too long"}
+	ErrExpectationFailed         = &Error{Status: 417, Message: "unsupported expectation"}
+	ErrHeadersTooLarge           = &Error{Status: 431, Message: "request header fields too large"}
+	ErrUnsupportedTransferCoding = &Error{Status: 501, Message: "transfer coding not implemented"}
+	ErrUnsupportedVersion        = &Error{Status: 505, Message: "HTTP version not supported"}
+)
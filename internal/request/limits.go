@@ -0,0 +1,34 @@
+package request
+
+// Limits bounds how much of a request the parser will accept. A zero field
+// means no limit.
+type Limits struct {
+	MaxRequestLineBytes int // length of the request-line, excluding CRLF
+	MaxHeaderBytes      int // total bytes of all header and trailer lines
+	MaxHeaderCount      int // number of header and trailer lines
+	MaxBodyBytes        int // decoded body length
+}
+
+// DefaultLimits are the limits used by RequestFromReader.
+var DefaultLimits = Limits{
+	MaxRequestLineBytes: 8 << 10,
+	MaxHeaderBytes:      64 << 10,
+	MaxHeaderCount:      100,
+	MaxBodyBytes:        10 << 20,
+}
+
+// DefaultStreamLimits are the limits used by StreamRequestFromReader. They
+// match DefaultLimits except that the body is unlimited, since a streamed
+// body is never held in memory as a whole.
+var DefaultStreamLimits = Limits{
+	MaxRequestLineBytes: DefaultLimits.MaxRequestLineBytes,
+	MaxHeaderBytes:      DefaultLimits.MaxHeaderBytes,
+	MaxHeaderCount:      DefaultLimits.MaxHeaderCount,
+}
+
+// maxChunkSizeLineBytes bounds a chunk-size line, extensions included.
+const maxChunkSizeLineBytes = 4 << 10
+
+func exceeds(limit int, n int) bool {
+	return limit > 0 && n > limit
+}
@@ -0,0 +1,64 @@
+package request
+
+import "io"
+
+// Reader reads successive requests from one connection. The parser reads
+// ahead of the request it is parsing, so bytes belonging to the next
+// pipelined request end up in its buffer; when a request is read from a
+// Reader, those bytes are handed back to the Reader rather than dropped, and
+// the next request picks them up.
+type Reader struct {
+	src io.Reader
+	buf []byte // read from src but not consumed yet
+}
+
+func NewReader(src io.Reader) *Reader {
+	return &Reader{src: src}
+}
+
+// Read reads buffered bytes first, then from the underlying reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		return r.src.Read(p)
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Peek returns the next n bytes without consuming them, reading from the
+// underlying reader until they are available. It returns fewer bytes only
+// along with an error.
+func (r *Reader) Peek(n int) ([]byte, error) {
+	for len(r.buf) < n {
+		chunk := make([]byte, max(n-len(r.buf), bufferSize))
+		numBytesRead, err := r.src.Read(chunk)
+		r.buf = append(r.buf, chunk[:numBytesRead]...)
+		if err != nil {
+			return r.buf, err
+		}
+	}
+	return r.buf[:n], nil
+}
+
+// Buffered returns the number of bytes that can be read without reading
+// from the underlying reader.
+func (r *Reader) Buffered() int {
+	return len(r.buf)
+}
+
+// unread puts p back in front of the buffered bytes.
+func (r *Reader) unread(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	r.buf = append(append(make([]byte, 0, len(p)+len(r.buf)), p...), r.buf...)
+}
+
+// unread hands p back to reader if it is a Reader. Bytes read from any other
+// reader past the end of a request are lost.
+func unread(reader io.Reader, p []byte) {
+	if rd, ok := reader.(*Reader); ok {
+		rd.unread(p)
+	}
+}
@@ -2,6 +2,7 @@ package request
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -20,6 +21,10 @@ const (
 	ParserInitialized ParserState = iota
 	ParserHeaders
 	ParserBody
+	ParserChunkSize
+	ParserChunkData
+	ParserChunkDataEnd
+	ParserTrailers
 	ParserDone
 )
 
@@ -31,9 +36,31 @@ type RequestLine struct {
 
 type Request struct {
 	RequestLine RequestLine
-	Headers     headers.Headers
+	Headers     *headers.Headers
 	Body        []byte
+	Trailers    *headers.Headers
 	State       ParserState
+
+	// BodyReader reads the request body. For requests read with
+	// StreamRequestFromReader it pulls from the connection on demand,
+	// otherwise it reads from the already buffered Body.
+	BodyReader io.ReadCloser
+
+	// Target is the parsed RequestLine.RequestTarget.
+	Target Target
+
+	// Params holds the path parameters captured by a router, keyed by name.
+	Params map[string]string
+
+	ctx            context.Context
+	limits         Limits
+	streaming      bool
+	pending        []byte
+	headerBytes    int
+	headerCount    int
+	bodyLength     int
+	contentLength  int // -1 if the request has no Content-Length
+	chunkRemaining uint64
 }
 
 func (r *Request) parse(data []byte) (n int, err error) {
@@ -54,6 +81,14 @@ func (r *Request) parse(data []byte) (n int, err error) {
 func (r *Request) parseSingle(data []byte) (n int, err error) {
 	switch r.State {
 	case ParserInitialized:
+		lineLength := bytes.Index(data, []byte(crlf))
+		if lineLength == -1 {
+			lineLength = len(data)
+		}
+		if exceeds(r.limits.MaxRequestLineBytes, lineLength) {
+			return 0, ErrRequestLineTooLong
+		}
+
 		n, requestLine, err := parseRequestLine(data)
 		if err != nil { // something went wrong
 			return 0, err
@@ -62,39 +97,100 @@ func (r *Request) parseSingle(data []byte) (n int, err error) {
 			return 0, nil
 		}
 
+		target, err := parseTarget(requestLine.Method, requestLine.RequestTarget)
+		if err != nil {
+			return 0, err
+		}
+
 		r.RequestLine = *requestLine
+		r.Target = *target
 		r.State = ParserHeaders
 		return n, nil
 	case ParserHeaders:
-		n, done, err := r.Headers.Parse(data)
+		n, done, err := r.parseHeaderLine(r.Headers, data)
 		if err != nil { // something went wrong
 			return 0, err
 		}
 		if done {
-			r.State = ParserBody
+			if err := r.checkExpect(); err != nil {
+				return 0, err
+			}
+			if err := r.checkFraming(); err != nil {
+				return 0, err
+			}
+			if err := r.checkHost(); err != nil {
+				return 0, err
+			}
 		}
 		return n, nil
 	case ParserBody:
-		lengthStr, ok := r.Headers.Get("content-length")
-		if !ok {
+		length := r.contentLength
+		if length == -1 {
 			r.State = ParserDone
 			return 0, nil
 		}
-		r.Body = append(r.Body, data...)
 
-		length, err := strconv.Atoi(lengthStr)
+		// Bytes past the end of the body belong to the next request
+		n := min(len(data), length-r.bodyLength)
+		if err := r.appendBody(data[:n]); err != nil {
+			return 0, err
+		}
+		if r.bodyLength == length {
+			r.State = ParserDone
+		}
+
+		return n, nil
+	case ParserChunkSize:
+		idx := bytes.Index(data, []byte(crlf))
+		if idx == -1 {
+			if len(data) > maxChunkSizeLineBytes {
+				return 0, fmt.Errorf("%w: chunk-size line too long", ErrMalformedChunk)
+			}
+			return 0, nil
+		}
+		size, err := parseChunkSize(string(data[:idx]))
 		if err != nil {
-			return 0, fmt.Errorf("error: invalid content-length: %w", err)
+			return 0, err
 		}
 
-		if len(r.Body) > length {
-			return 0, fmt.Errorf("error: body is larger than content-length %v != %v", len(r.Body), length)
+		if size == 0 {
+			r.State = ParserTrailers
+		} else {
+			r.chunkRemaining = size
+			r.State = ParserChunkData
+		}
+		return idx + 2, nil
+	case ParserChunkData:
+		n := uint64(len(data))
+		if n > r.chunkRemaining {
+			n = r.chunkRemaining
+		}
+		if err := r.appendBody(data[:n]); err != nil {
+			return 0, err
 		}
-		if len(r.Body) == length {
+		r.chunkRemaining -= n
+		if r.chunkRemaining == 0 {
+			r.State = ParserChunkDataEnd
+		}
+		return int(n), nil
+	case ParserChunkDataEnd:
+		if len(data) < 2 {
+			return 0, nil
+		}
+		if string(data[:2]) != crlf {
+			return 0, fmt.Errorf("%w: chunk data not terminated by CRLF", ErrMalformedChunk)
+		}
+		r.State = ParserChunkSize
+		return 2, nil
+	case ParserTrailers:
+		n, done, err := r.parseHeaderLine(r.Trailers, data)
+		if err != nil {
+			return 0, err
+		}
+		if done {
 			r.State = ParserDone
 		}
-
-		return len(data), nil
+		return n, nil
 	case ParserDone:
 		return 0, fmt.Errorf("error: trying to read data in a done state")
 	default:
@@ -102,6 +198,192 @@ func (r *Request) parseSingle(data []byte) (n int, err error) {
 	}
 }
 
+// parseHeaderLine parses a single header or trailer line into h, enforcing
+// the header size and count limits across both sections.
+func (r *Request) parseHeaderLine(h *headers.Headers, data []byte) (n int, done bool, err error) {
+	n, done, err = h.Parse(data)
+	if err != nil {
+		return 0, false, err
+	}
+	if n == 0 {
+		if exceeds(r.limits.MaxHeaderBytes, r.headerBytes+len(data)) {
+			return 0, false, ErrHeadersTooLarge
+		}
+		return 0, false, nil
+	}
+
+	r.headerBytes += n
+	if !done {
+		r.headerCount++
+	}
+	if exceeds(r.limits.MaxHeaderBytes, r.headerBytes) || exceeds(r.limits.MaxHeaderCount, r.headerCount) {
+		return 0, false, ErrHeadersTooLarge
+	}
+	return n, done, nil
+}
+
+// checkExpect rejects expectations other than 100-continue, the only one
+// defined by HTTP/1.1. Expect is ignored in HTTP/1.0 requests.
+func (r *Request) checkExpect() error {
+	if r.IsHTTP10() {
+		return nil
+	}
+	value, ok := r.Headers.Get("expect")
+	if !ok || strings.EqualFold(strings.TrimSpace(value), "100-continue") {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrExpectationFailed, value)
+}
+
+// ExpectsContinue reports whether the client sent "Expect: 100-continue" and
+// is waiting for a 100 Continue response before sending the body.
+func (r *Request) ExpectsContinue() bool {
+	return !r.IsHTTP10() && r.Headers.HasToken("expect", "100-continue")
+}
+
+// checkHost requires an HTTP/1.1 request to carry exactly one Host field with
+// a valid host and optional port. The field is optional in HTTP/1.0, but must
+// still be valid and unique if present. An empty Host is allowed, as sent for
+// targets without an authority.
+func (r *Request) checkHost() error {
+	values := r.Headers.Values("host")
+	switch {
+	case len(values) == 0 && r.IsHTTP10():
+		return nil
+	case len(values) == 0:
+		return fmt.Errorf("%w: missing host", ErrInvalidHost)
+	case len(values) > 1:
+		return fmt.Errorf("%w: %d host fields", ErrInvalidHost, len(values))
+	case !validHost(values[0]):
+		return fmt.Errorf("%w: %q", ErrInvalidHost, values[0])
+	}
+	return nil
+}
+
+// Host returns the host the request is addressed to: the authority of an
+// absolute-form target, which takes precedence as required by RFC 9112, or
+// else the Host header. It may include a port and is "" if the client did not
+// name a host.
+func (r *Request) Host() string {
+	if r.Target.Form == AbsoluteForm {
+		return r.Target.Authority
+	}
+	host, _ := r.Headers.Get("host")
+	return host
+}
+
+// checkFraming determines how the body is delimited following the message
+// length rules of RFC 9112 and moves the parser to the matching state. Any
+// framing that two parties could read differently is rejected, since a proxy
+// in front of the server might otherwise disagree on where the request ends:
+// Transfer-Encoding together with Content-Length, Transfer-Encoding in an
+// HTTP/1.0 request, transfer codings other than a single chunked, and
+// Content-Length values that are not plain digits or disagree. A body that is
+// announced to be larger than the limit is rejected before any of it is read.
+func (r *Request) checkFraming() error {
+	_, hasEncoding := r.Headers.Get("transfer-encoding")
+	_, hasLength := r.Headers.Get("content-length")
+	if hasEncoding {
+		if hasLength {
+			return fmt.Errorf("%w: both transfer-encoding and content-length", ErrAmbiguousFraming)
+		}
+		if r.IsHTTP10() {
+			return fmt.Errorf("%w: transfer-encoding in an HTTP/1.0 request", ErrAmbiguousFraming)
+		}
+		if err := checkTransferEncoding(r.Headers.Values("transfer-encoding")); err != nil {
+			return err
+		}
+		r.State = ParserChunkSize
+		return nil
+	}
+
+	r.State = ParserBody
+	if !hasLength {
+		return nil
+	}
+	length, err := parseContentLength(r.Headers.Values("content-length"))
+	if err != nil {
+		return err
+	}
+	if exceeds(r.limits.MaxBodyBytes, length) {
+		return ErrBodyTooLarge
+	}
+	r.contentLength = length
+	return nil
+}
+
+// checkTransferEncoding accepts exactly one transfer coding, chunked, across
+// all Transfer-Encoding fields.
+func checkTransferEncoding(values []string) error {
+	var codings []string
+	for _, value := range values {
+		for _, coding := range strings.Split(value, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding == "" {
+				continue
+			}
+			if coding != "chunked" {
+				return fmt.Errorf("%w: %s", ErrUnsupportedTransferCoding, coding)
+			}
+			codings = append(codings, coding)
+		}
+	}
+	if len(codings) != 1 {
+		return fmt.Errorf("%w: transfer-encoding %q", ErrAmbiguousFraming, strings.Join(values, ", "))
+	}
+	return nil
+}
+
+// parseContentLength parses the Content-Length fields, each of which may hold
+// a comma-separated list. Every value must be a non-empty string of digits,
+// and repeated values must all be the same.
+func parseContentLength(values []string) (int, error) {
+	length := -1
+	for _, value := range values {
+		for _, s := range strings.Split(value, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+				return 0, fmt.Errorf("%w: %q", ErrInvalidContentLength, s)
+			}
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %w", ErrInvalidContentLength, err)
+			}
+			if length != -1 && n != length {
+				return 0, fmt.Errorf("%w: conflicting content-length values %d and %d", ErrAmbiguousFraming, length, n)
+			}
+			length = n
+		}
+	}
+	return length, nil
+}
+
+// parseChunkSize parses a chunk-size line of the form
+// chunk-size [ ";" chunk-ext-name [ "=" chunk-ext-val ] ]*. Chunk extensions
+// are validated but otherwise ignored, as no extensions are understood.
+func parseChunkSize(line string) (uint64, error) {
+	sizeStr, extensions, _ := strings.Cut(line, ";")
+	sizeStr = strings.TrimRight(sizeStr, " \t")
+	if len(sizeStr) == 0 {
+		return 0, fmt.Errorf("%w: missing chunk size", ErrMalformedChunk)
+	}
+	size, err := strconv.ParseUint(sizeStr, 16, 63)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid chunk size %q: %w", ErrMalformedChunk, sizeStr, err)
+	}
+
+	if len(extensions) > 0 {
+		for _, ext := range strings.Split(extensions, ";") {
+			name, _, _ := strings.Cut(ext, "=")
+			if len(strings.TrimSpace(name)) == 0 {
+				return 0, fmt.Errorf("%w: invalid chunk extension: %s", ErrMalformedChunk, ext)
+			}
+		}
+	}
+
+	return size, nil
+}
+
 func parseRequestLine(data []byte) (int, *RequestLine, error) {
 	idx := bytes.Index(data, []byte(crlf))
 	if idx == -1 {
@@ -118,13 +400,13 @@ func parseRequestLine(data []byte) (int, *RequestLine, error) {
 func requestLineFromString(str string) (*RequestLine, error) {
 	parts := strings.Split(str, " ")
 	if len(parts) != 3 {
-		return nil, fmt.Errorf("poorly formatted request-line: %s", str)
+		return nil, fmt.Errorf("%w: %s", ErrMalformedRequestLine, str)
 	}
 
 	method := parts[0]
 	for _, c := range method {
 		if c < 'A' || c > 'Z' {
-			return nil, fmt.Errorf("invalid method: %s", method)
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMethod, method)
 		}
 	}
 
@@ -132,16 +414,19 @@ func requestLineFromString(str string) (*RequestLine, error) {
 
 	versionParts := strings.Split(parts[2], "/")
 	if len(versionParts) != 2 {
-		return nil, fmt.Errorf("malformed start-line: %s", str)
+		return nil, fmt.Errorf("%w: %s", ErrMalformedRequestLine, str)
 	}
 
 	httpPart := versionParts[0]
 	if httpPart != "HTTP" {
-		return nil, fmt.Errorf("unrecognized HTTP-version: %s", httpPart)
+		return nil, fmt.Errorf("%w: unrecognized HTTP-version: %s", ErrMalformedRequestLine, httpPart)
 	}
 	version := versionParts[1]
-	if version != "1.1" {
-		return nil, fmt.Errorf("unrecognized HTTP-version: %s", version)
+	if !validVersion(version) {
+		return nil, fmt.Errorf("%w: malformed HTTP-version: %s", ErrMalformedRequestLine, version)
+	}
+	if version != "1.0" && version != "1.1" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
 	}
 
 	return &RequestLine{
@@ -151,16 +436,102 @@ func requestLineFromString(str string) (*RequestLine, error) {
 	}, nil
 }
 
+// validVersion reports whether version has the DIGIT "." DIGIT form of an
+// HTTP-version number.
+func validVersion(version string) bool {
+	return len(version) == 3 &&
+		version[0] >= '0' && version[0] <= '9' &&
+		version[1] == '.' &&
+		version[2] >= '0' && version[2] <= '9'
+}
+
+// RequestFromReader reads a complete request from reader, buffering the
+// whole body into Body. DefaultLimits apply. If reader is a Reader, bytes
+// read past the end of the request are left in it for the next request.
 func RequestFromReader(reader io.Reader) (*Request, error) {
+	return RequestFromReaderWithLimits(reader, DefaultLimits)
+}
+
+// RequestFromReaderWithLimits is like RequestFromReader but rejects requests
+// exceeding limits.
+func RequestFromReaderWithLimits(reader io.Reader, limits Limits) (*Request, error) {
+	req := newRequest(limits, false)
+	leftover, err := req.readFrom(reader, ParserDone)
+	if err != nil {
+		return nil, err
+	}
+	unread(reader, leftover)
+	req.BodyReader = io.NopCloser(bytes.NewReader(req.Body))
+	return req, nil
+}
+
+// StreamRequestFromReader reads the request line and headers from reader and
+// returns as soon as they are parsed. Body is left empty; the body is instead
+// pulled from reader on demand through BodyReader, following the
+// Content-Length or chunked framing of the request. DefaultStreamLimits
+// apply. If reader is a Reader, bytes read past the end of the request are
+// left in it once the body has been read to the end.
+func StreamRequestFromReader(reader io.Reader) (*Request, error) {
+	return StreamRequestFromReaderWithLimits(reader, DefaultStreamLimits)
+}
+
+// StreamRequestFromReaderWithLimits is like StreamRequestFromReader but
+// rejects requests exceeding limits. MaxBodyBytes is enforced as the body is
+// read.
+func StreamRequestFromReaderWithLimits(reader io.Reader, limits Limits) (*Request, error) {
+	req := newRequest(limits, true)
+	leftover, err := req.readFrom(reader, ParserBody)
+	if err != nil {
+		return nil, err
+	}
+	if req.State == ParserDone {
+		unread(reader, leftover)
+		leftover = nil
+	}
+	req.BodyReader = &bodyReader{
+		req: req,
+		src: reader,
+		buf: leftover,
+	}
+	return req, nil
+}
+
+// ReadBody reads the rest of a streamed body into Body, so the request can
+// be used as if it had been read by RequestFromReader.
+func (r *Request) ReadBody() error {
+	if !r.streaming {
+		return nil
+	}
+	body, err := io.ReadAll(r.BodyReader)
+	if err != nil {
+		return err
+	}
+	r.Body = append(r.Body, body...)
+	r.BodyReader = io.NopCloser(bytes.NewReader(r.Body))
+	r.streaming = false
+	return nil
+}
+
+func newRequest(limits Limits, streaming bool) *Request {
+	return &Request{
+		limits:    limits,
+		Headers:   headers.NewHeaders(),
+		Trailers:  headers.NewHeaders(),
+		State:     ParserInitialized,
+		Body:      make([]byte, 0),
+		streaming: streaming,
+
+		contentLength: -1,
+	}
+}
+
+// readFrom reads from reader until the parser has moved past the given state,
+// returning any bytes that were read but not yet parsed.
+func (r *Request) readFrom(reader io.Reader, until ParserState) ([]byte, error) {
 	buf := make([]byte, bufferSize)
 	readToIndex := 0
-	req := &Request{
-		Headers: headers.NewHeaders(),
-		State:   ParserInitialized,
-		Body:    make([]byte, 0),
-	}
 
-	for req.State != ParserDone {
+	for r.State < until {
 		// Resize buffer to twice current size if full
 		if readToIndex >= cap(buf) {
 			newBuf := make([]byte, len(buf)*2)
@@ -172,8 +543,8 @@ func RequestFromReader(reader io.Reader) (*Request, error) {
 		numBytesRead, err := reader.Read(buf[readToIndex:])
 		if err != nil {
 			if errors.Is(err, io.EOF) {
-				if req.State != ParserDone {
-					return nil, fmt.Errorf("incomplete request: %w", err)
+				if r.State < until {
+					return nil, fmt.Errorf("%w: %w", ErrIncompleteRequest, err)
 				}
 				break
 			}
@@ -183,7 +554,7 @@ func RequestFromReader(reader io.Reader) (*Request, error) {
 		readToIndex += numBytesRead
 
 		// Try to parse the request
-		numBytesParsed, err := req.parse(buf[:readToIndex])
+		numBytesParsed, err := r.parse(buf[:readToIndex])
 		if err != nil {
 			return nil, err
 		}
@@ -193,7 +564,75 @@ func RequestFromReader(reader io.Reader) (*Request, error) {
 		readToIndex -= numBytesParsed
 	}
 
-	return req, nil
+	return buf[:readToIndex], nil
+}
+
+// appendBody stores decoded body bytes, either in Body or, when streaming,
+// in the pending buffer drained by BodyReader.
+func (r *Request) appendBody(p []byte) error {
+	if exceeds(r.limits.MaxBodyBytes, r.bodyLength+len(p)) {
+		return ErrBodyTooLarge
+	}
+	r.bodyLength += len(p)
+	if r.streaming {
+		r.pending = append(r.pending, p...)
+	} else {
+		r.Body = append(r.Body, p...)
+	}
+	return nil
+}
+
+// Context returns the request's context. For requests served by the server it
+// is cancelled when the client disconnects, the server is closed or the
+// response deadline passes; otherwise it is context.Background.
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// SetContext replaces the request's context. ctx must not be nil.
+func (r *Request) SetContext(ctx context.Context) {
+	if ctx == nil {
+		panic("request: nil context")
+	}
+	r.ctx = ctx
+}
+
+// SetValue attaches value to the request's context under key. The request is
+// shared by the handler and the middleware around it, so values set by the
+// handler can be read by middleware once the handler returns.
+func (r *Request) SetValue(key, value any) {
+	r.ctx = context.WithValue(r.Context(), key, value)
+}
+
+// Value returns the value attached to the request's context under key, or nil
+// if there is none.
+func (r *Request) Value(key any) any {
+	return r.Context().Value(key)
+}
+
+// Param returns the path parameter captured under name, or "" if there is
+// none.
+func (r *Request) Param(name string) string {
+	return r.Params[name]
+}
+
+// KeepAlive reports whether the client expects the connection to stay open
+// once the response to this request has been written. HTTP/1.1 connections
+// are persistent unless the client sends "Connection: close", HTTP/1.0 ones
+// only if the client sends "Connection: keep-alive".
+func (r *Request) KeepAlive() bool {
+	if r.IsHTTP10() {
+		return r.Headers.HasToken("connection", "keep-alive")
+	}
+	return !r.Headers.HasToken("connection", "close")
+}
+
+// IsHTTP10 reports whether the request was sent with HTTP/1.0.
+func (r *Request) IsHTTP10() bool {
+	return r.RequestLine.HttpVersion == "1.0"
 }
 
 func PrintRequest(req *Request) {
@@ -203,7 +642,7 @@ func PrintRequest(req *Request) {
 	fmt.Printf("- Version: %s\n", req.RequestLine.HttpVersion)
 
 	fmt.Println("Headers:")
-	for k, v := range req.Headers {
+	for k, v := range req.Headers.All() {
 		fmt.Printf("- %s: %s\n", k, v)
 	}
 
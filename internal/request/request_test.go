@@ -1,10 +1,13 @@
 package request
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
 
+	"github.com/evanwiseman/httpfromtcp/internal/headers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -101,29 +104,31 @@ func TestRequestHeadersParse(t *testing.T) {
 	r, err := RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "localhost:42069", r.Headers["host"])
-	assert.Equal(t, "curl/7.81.0", r.Headers["user-agent"])
-	assert.Equal(t, "*/*", r.Headers["accept"])
+	assert.Equal(t, []string{"localhost:42069"}, r.Headers.Values("host"))
+	assert.Equal(t, []string{"curl/7.81.0"}, r.Headers.Values("user-agent"))
+	assert.Equal(t, []string{"*/*"}, r.Headers.Values("accept"))
 
 	// Test: Empty Headers
 	reader = &chunkReader{
-		data:            "GET / HTTP/1.1\r\n\r\n",
+		data:            "GET / HTTP/1.0\r\n\r\n",
 		numBytesPerRead: 3,
 	}
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, len(r.Headers), 0)
+	assert.Equal(t, r.Headers.Len(), 0)
 
 	// Test: Duplicate Headers
 	reader = &chunkReader{
-		data:            "GET / HTTP/1.1\r\nSet-Person: lane-loves-go\r\nSet-Person: prime-loves-zig\r\n\r\n",
+		data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\nSet-Person: lane-loves-go\r\nSet-Person: prime-loves-zig\r\n\r\n",
 		numBytesPerRead: 3,
 	}
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "lane-loves-go, prime-loves-zig", r.Headers["set-person"])
+	value, ok := r.Headers.Get("set-person")
+	assert.True(t, ok)
+	assert.Equal(t, "lane-loves-go, prime-loves-zig", value)
 
 	// Test: Case-insensitive headers
 	reader = &chunkReader{
@@ -133,9 +138,9 @@ func TestRequestHeadersParse(t *testing.T) {
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "localhost:42069", r.Headers["host"])
-	assert.Equal(t, "curl/7.81.0", r.Headers["user-agent"])
-	assert.Equal(t, "*/*", r.Headers["accept"])
+	assert.Equal(t, []string{"localhost:42069"}, r.Headers.Values("host"))
+	assert.Equal(t, []string{"curl/7.81.0"}, r.Headers.Values("user-agent"))
+	assert.Equal(t, []string{"*/*"}, r.Headers.Values("accept"))
 
 	// Test: Malformed Header
 	reader = &chunkReader{
@@ -220,3 +225,553 @@ func TestRequestBodyParse(t *testing.T) {
 	require.NotNil(t, r)
 	assert.Equal(t, "", string(r.Body))
 }
+
+func TestRequestChunkedBodyParse(t *testing.T) {
+	// Test: Standard chunked body
+	reader := &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"6\r\nhello \r\n" +
+			"7\r\nworld!\n\r\n" +
+			"0\r\n" +
+			"\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "hello world!\n", string(r.Body))
+	assert.Equal(t, 0, r.Trailers.Len())
+
+	// Test: Chunk extensions and trailers
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"Trailer: X-Checksum\r\n" +
+			"\r\n" +
+			"a;name=value;flag\r\n0123456789\r\n" +
+			"0\r\n" +
+			"X-Checksum: abc123\r\n" +
+			"\r\n",
+		numBytesPerRead: 5,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "0123456789", string(r.Body))
+	assert.Equal(t, []string{"abc123"}, r.Trailers.Values("x-checksum"))
+
+	// Test: Invalid chunk size
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"zz\r\nhello\r\n" +
+			"0\r\n" +
+			"\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+
+	// Test: Chunk longer than its declared size
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"3\r\nhello\r\n" +
+			"0\r\n" +
+			"\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+
+	// Test: Missing last chunk
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"5\r\nhello\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.Error(t, err)
+}
+
+func TestRequestStreamBody(t *testing.T) {
+	// Test: Content-Length body is read on demand
+	reader := &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Content-Length: 13\r\n" +
+			"\r\n" +
+			"hello world!\n",
+		numBytesPerRead: 3,
+	}
+	r, err := StreamRequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "POST", r.RequestLine.Method)
+	assert.Equal(t, "", string(r.Body))
+	body, err := io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!\n", string(body))
+	assert.Equal(t, ParserDone, r.State)
+
+	// Test: Chunked body is decoded on demand
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"6\r\nhello \r\n" +
+			"7\r\nworld!\n\r\n" +
+			"0\r\n" +
+			"X-Checksum: abc123\r\n" +
+			"\r\n",
+		numBytesPerRead: 4,
+	}
+	r, err = StreamRequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	body, err = io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!\n", string(body))
+	assert.Equal(t, []string{"abc123"}, r.Trailers.Values("x-checksum"))
+
+	// Test: No body
+	reader = &chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err = StreamRequestFromReader(reader)
+	require.NoError(t, err)
+	body, err = io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Equal(t, "", string(body))
+
+	// Test: Body shorter than reported content length
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Content-Length: 20\r\n" +
+			"\r\n" +
+			"partial content",
+		numBytesPerRead: 3,
+	}
+	r, err = StreamRequestFromReader(reader)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r.BodyReader)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestRequestLimits(t *testing.T) {
+	limits := Limits{
+		MaxRequestLineBytes: 32,
+		MaxHeaderBytes:      64,
+		MaxHeaderCount:      2,
+		MaxBodyBytes:        8,
+	}
+
+	// Test: Request within limits
+	reader := &chunkReader{
+		data:            "POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5\r\n\r\nhello",
+		numBytesPerRead: 3,
+	}
+	r, err := RequestFromReaderWithLimits(reader, limits)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(r.Body))
+
+	// Test: Request-line too long
+	reader = &chunkReader{
+		data:            "GET /" + strings.Repeat("a", 64) + " HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReaderWithLimits(reader, limits)
+	require.ErrorIs(t, err, ErrRequestLineTooLong)
+
+	// Test: Request-line without CRLF never ends
+	reader = &chunkReader{
+		data:            "GET /" + strings.Repeat("a", 1024),
+		numBytesPerRead: 16,
+	}
+	_, err = RequestFromReaderWithLimits(reader, limits)
+	require.ErrorIs(t, err, ErrRequestLineTooLong)
+
+	// Test: Too many headers
+	reader = &chunkReader{
+		data:            "GET / HTTP/1.1\r\nA: 1\r\nB: 2\r\nC: 3\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReaderWithLimits(reader, limits)
+	require.ErrorIs(t, err, ErrHeadersTooLarge)
+
+	// Test: Header line too long
+	reader = &chunkReader{
+		data:            "GET / HTTP/1.1\r\nX-Long: " + strings.Repeat("a", 128) + "\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReaderWithLimits(reader, limits)
+	require.ErrorIs(t, err, ErrHeadersTooLarge)
+
+	// Test: Announced body too large
+	reader = &chunkReader{
+		data:            "POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 100\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReaderWithLimits(reader, limits)
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+
+	// Test: Chunked body grows too large
+	reader = &chunkReader{
+		data:            "POST / HTTP/1.1\r\nHost: localhost:42069\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n5\r\nworld\r\n0\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReaderWithLimits(reader, limits)
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+}
+
+func TestRequestStreamLimits(t *testing.T) {
+	// Test: Streamed bodies are unlimited by default
+	data := fmt.Sprintf("POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: %d\r\n\r\n", DefaultLimits.MaxBodyBytes+1)
+	_, err := StreamRequestFromReader(strings.NewReader(data))
+	require.NoError(t, err)
+
+	// Test: Buffered bodies keep the default limit
+	_, err = RequestFromReader(strings.NewReader(data))
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+}
+
+func TestRequestReadBody(t *testing.T) {
+	// Test: Streamed body buffered into Body
+	reader := &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Content-Length: 13\r\n" +
+			"\r\n" +
+			"hello world!\n",
+		numBytesPerRead: 3,
+	}
+	r, err := StreamRequestFromReader(reader)
+	require.NoError(t, err)
+	require.NoError(t, r.ReadBody())
+	assert.Equal(t, "hello world!\n", string(r.Body))
+	body, err := io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!\n", string(body))
+}
+
+func TestRequestExpect(t *testing.T) {
+	// Test: 100-continue is accepted
+	r, err := StreamRequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost:42069\r\nExpect: 100-Continue\r\nContent-Length: 0\r\n\r\n"))
+	require.NoError(t, err)
+	assert.True(t, r.ExpectsContinue())
+
+	// Test: Other expectations are rejected
+	_, err = StreamRequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost:42069\r\nExpect: something\r\n\r\n"))
+	require.ErrorIs(t, err, ErrExpectationFailed)
+}
+
+func TestRequestHTTP10(t *testing.T) {
+	// Test: HTTP/1.0 request line
+	r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.0\r\nUser-Agent: ApacheBench/2.3\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", r.RequestLine.HttpVersion)
+	assert.True(t, r.IsHTTP10())
+
+	// Test: HTTP/1.0 connections are not persistent by default
+	assert.False(t, r.KeepAlive())
+
+	// Test: HTTP/1.0 keep-alive is opt-in
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.0\r\nConnection: Keep-Alive\r\n\r\n"))
+	require.NoError(t, err)
+	assert.True(t, r.KeepAlive())
+
+	// Test: HTTP/1.1 connections are persistent by default
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+	require.NoError(t, err)
+	assert.False(t, r.IsHTTP10())
+	assert.True(t, r.KeepAlive())
+
+	// Test: Expect is ignored for HTTP/1.0
+	r, err = StreamRequestFromReader(strings.NewReader("POST / HTTP/1.0\r\nExpect: 100-continue\r\nContent-Length: 0\r\n\r\n"))
+	require.NoError(t, err)
+	assert.False(t, r.ExpectsContinue())
+	_, err = StreamRequestFromReader(strings.NewReader("POST / HTTP/1.0\r\nExpect: something\r\nContent-Length: 0\r\n\r\n"))
+	require.NoError(t, err)
+}
+
+func TestRequestTarget(t *testing.T) {
+	// Test: Origin-form with decoded path and query
+	r, err := RequestFromReader(strings.NewReader("GET /video%20clips/a%2Fb/?x=1&tag=a+b&tag=c%26d&flag HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, OriginForm, r.Target.Form)
+	assert.Equal(t, "/video clips/a/b/", r.Target.Path)
+	assert.Equal(t, "/video%20clips/a%2Fb/", r.Target.RawPath)
+	assert.Equal(t, []string{"video clips", "a/b"}, r.Target.Segments())
+	assert.Equal(t, "x=1&tag=a+b&tag=c%26d&flag", r.Target.RawQuery)
+	assert.Equal(t, "1", r.Target.Query.Get("x"))
+	assert.Equal(t, []string{"a b", "c&d"}, r.Target.Query["tag"])
+	assert.True(t, r.Target.Query.Has("flag"))
+	assert.False(t, r.Target.Query.Has("missing"))
+
+	// Test: Root path has no segments
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "/", r.Target.Path)
+	assert.Empty(t, r.Target.Segments())
+	assert.Empty(t, r.Target.Query)
+
+	// Test: Absolute-form
+	r, err = RequestFromReader(strings.NewReader("GET HTTP://example.com:8080/a?b=c HTTP/1.1\r\nHost: example.com:8080\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, AbsoluteForm, r.Target.Form)
+	assert.Equal(t, "http", r.Target.Scheme)
+	assert.Equal(t, "example.com:8080", r.Target.Authority)
+	assert.Equal(t, "/a", r.Target.Path)
+	assert.Equal(t, "c", r.Target.Query.Get("b"))
+
+	// Test: Absolute-form without a path
+	r, err = RequestFromReader(strings.NewReader("GET http://example.com HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "/", r.Target.Path)
+
+	// Test: Authority-form for CONNECT
+	r, err = RequestFromReader(strings.NewReader("CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, AuthorityForm, r.Target.Form)
+	assert.Equal(t, "example.com:443", r.Target.Authority)
+	assert.Empty(t, r.Target.Path)
+
+	// Test: Asterisk-form for OPTIONS
+	r, err = RequestFromReader(strings.NewReader("OPTIONS * HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, AsteriskForm, r.Target.Form)
+
+	// Test: Invalid targets
+	for _, target := range []string{
+		"/%zz",
+		"/a%2",
+		"/a?b=%g1",
+		"/a%00b",
+		"/../etc/passwd",
+		"/a/%2e%2E/b",
+		"/a%2F..%2F..%2Fetc%2Fpasswd",
+		"/static/..%2fsecret",
+		"/static/a%2f..",
+		"/..",
+		"relative/path",
+		"http:///nohost",
+		"1http://example.com/",
+	} {
+		_, err = RequestFromReader(strings.NewReader("GET " + target + " HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		assert.ErrorIs(t, err, ErrInvalidTarget, target)
+	}
+	_, err = RequestFromReader(strings.NewReader("GET * HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+	_, err = RequestFromReader(strings.NewReader("CONNECT /path HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+}
+
+func TestRequestFraming(t *testing.T) {
+	// Test: Repeated identical Content-Length values are merged
+	r, err := RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5, 5\r\nContent-Length: 5\r\n\r\nhello"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(r.Body))
+
+	// Test: Transfer-Encoding is case-insensitive
+	r, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost:42069\r\nTransfer-Encoding: Chunked\r\n\r\n2\r\nhi\r\n0\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(r.Body))
+
+	tests := []struct {
+		name    string
+		headers string
+		target  error
+	}{
+		{"both headers", "Content-Length: 5\r\nTransfer-Encoding: chunked\r\n", ErrAmbiguousFraming},
+		{"both headers reversed", "Transfer-Encoding: chunked\r\nContent-Length: 5\r\n", ErrAmbiguousFraming},
+		{"conflicting lengths", "Content-Length: 5\r\nContent-Length: 7\r\n", ErrAmbiguousFraming},
+		{"conflicting list", "Content-Length: 5, 7\r\n", ErrAmbiguousFraming},
+		{"leading plus", "Content-Length: +5\r\n", ErrInvalidContentLength},
+		{"negative", "Content-Length: -5\r\n", ErrInvalidContentLength},
+		{"hex", "Content-Length: 0x5\r\n", ErrInvalidContentLength},
+		{"empty", "Content-Length: \r\n", ErrInvalidContentLength},
+		{"empty list element", "Content-Length: 5,\r\n", ErrInvalidContentLength},
+		{"overflow", "Content-Length: 99999999999999999999999\r\n", ErrInvalidContentLength},
+		{"unknown coding", "Transfer-Encoding: gzip, chunked\r\n", ErrUnsupportedTransferCoding},
+		{"identity coding", "Transfer-Encoding: identity\r\n", ErrUnsupportedTransferCoding},
+		{"chunked twice", "Transfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n", ErrAmbiguousFraming},
+		{"empty coding", "Transfer-Encoding: \r\n", ErrAmbiguousFraming},
+		{"obs-fold", "Transfer-Encoding: \r\n chunked\r\n", headers.ErrMalformedField},
+	}
+	for _, tt := range tests {
+		// Test: Ambiguous framing is rejected
+		_, err := RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost:42069\r\n" + tt.headers + "\r\n5\r\nhello\r\n0\r\n\r\n"))
+		assert.ErrorIs(t, err, tt.target, tt.name)
+	}
+
+	// Test: Whitespace before the first field line
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\n Transfer-Encoding: chunked\r\nHost: localhost:42069\r\n\r\n0\r\n\r\n"))
+	assert.ErrorIs(t, err, headers.ErrMalformedField)
+
+	// Test: Transfer-Encoding in an HTTP/1.0 request
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.0\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrAmbiguousFraming)
+	assert.Equal(t, 501, ErrUnsupportedTransferCoding.StatusCode())
+}
+
+func TestRequestHost(t *testing.T) {
+	// Test: Valid hosts
+	for _, host := range []string{
+		"localhost",
+		"localhost:42069",
+		"API.Example.test",
+		"127.0.0.1:8080",
+		"[::1]",
+		"[::1]:8080",
+		"my_host.local",
+		"",
+	} {
+		r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: " + host + "\r\n\r\n"))
+		require.NoError(t, err, host)
+		assert.Equal(t, host, r.Host())
+	}
+
+	// Test: Invalid hosts
+	for _, host := range []string{
+		"local host",
+		"localhost:80a",
+		"user@localhost",
+		"localhost/path",
+		"[::1",
+		"[]:80",
+		":80",
+		"localhost, example.test",
+	} {
+		_, err := RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: " + host + "\r\n\r\n"))
+		assert.ErrorIs(t, err, ErrInvalidHost, host)
+	}
+
+	// Test: HTTP/1.1 requires exactly one Host
+	_, err := RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrInvalidHost)
+	_, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: a.test\r\nHost: b.test\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrInvalidHost)
+
+	// Test: HTTP/1.0 may leave out Host
+	r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.0\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Empty(t, r.Host())
+	_, err = RequestFromReader(strings.NewReader("GET / HTTP/1.0\r\nHost: a.test\r\nHost: b.test\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrInvalidHost)
+
+	// Test: Absolute-form authority takes precedence over Host
+	r, err = RequestFromReader(strings.NewReader("GET http://api.example.test/ HTTP/1.1\r\nHost: other.test\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "api.example.test", r.Host())
+}
+
+func TestRequestPipelining(t *testing.T) {
+	pipelined := "POST /first HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5\r\n\r\nhello" +
+		"POST /second HTTP/1.1\r\nHost: localhost:42069\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nworld\r\n0\r\n\r\n" +
+		"GET /third HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"
+	targets := []string{"/first", "/second", "/third"}
+	bodies := []string{"hello", "world", ""}
+
+	// Test: Buffered requests read in order from one Reader
+	for _, numBytesPerRead := range []int{1, 7, len(pipelined)} {
+		reader := NewReader(&chunkReader{data: pipelined, numBytesPerRead: numBytesPerRead})
+		for i, target := range targets {
+			r, err := RequestFromReader(reader)
+			require.NoError(t, err)
+			assert.Equal(t, target, r.RequestLine.RequestTarget)
+			assert.Equal(t, bodies[i], string(r.Body))
+		}
+		_, err := reader.Peek(1)
+		assert.ErrorIs(t, err, io.EOF)
+	}
+
+	// Test: Streamed requests read in order once each body is drained
+	for _, numBytesPerRead := range []int{1, 7, len(pipelined)} {
+		reader := NewReader(&chunkReader{data: pipelined, numBytesPerRead: numBytesPerRead})
+		for i, target := range targets {
+			r, err := StreamRequestFromReader(reader)
+			require.NoError(t, err)
+			assert.Equal(t, target, r.RequestLine.RequestTarget)
+			body, err := io.ReadAll(r.BodyReader)
+			require.NoError(t, err)
+			assert.Equal(t, bodies[i], string(body))
+		}
+		assert.Equal(t, 0, reader.Buffered())
+	}
+
+	// Test: Peek does not consume
+	reader := NewReader(strings.NewReader(pipelined))
+	peeked, err := reader.Peek(4)
+	require.NoError(t, err)
+	assert.Equal(t, "POST", string(peeked))
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "POST", r.RequestLine.Method)
+}
+
+func TestRequestContext(t *testing.T) {
+	type key struct{}
+
+	// Test: Parsed requests default to the background context
+	r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, context.Background(), r.Context())
+	assert.Nil(t, r.Value(key{}))
+
+	// Test: Values are layered on the current context
+	ctx, cancel := context.WithCancel(context.Background())
+	r.SetContext(ctx)
+	r.SetValue(key{}, "trace-1")
+	assert.Equal(t, "trace-1", r.Value(key{}))
+	cancel()
+	assert.ErrorIs(t, r.Context().Err(), context.Canceled)
+
+	// Test: Zero requests have a usable context
+	r = &Request{}
+	r.SetValue(key{}, 1)
+	assert.Equal(t, 1, r.Value(key{}))
+	assert.Panics(t, func() { r.SetContext(nil) })
+}
+
+func TestRequestErrorStatus(t *testing.T) {
+	tests := []struct {
+		data   string
+		target *Error
+	}{
+		{"GET /coffee\r\n\r\n", ErrMalformedRequestLine},
+		{"G3T / HTTP/1.1\r\n\r\n", ErrInvalidMethod},
+		{"GET /%zz HTTP/1.1\r\n\r\n", ErrInvalidTarget},
+		{"GET / HTTP/1.2\r\n\r\n", ErrUnsupportedVersion},
+		{"GET / HTTP/2.0\r\n\r\n", ErrUnsupportedVersion},
+		{"GET / HTTP/1.x\r\n\r\n", ErrMalformedRequestLine},
+		{"POST / HTTP/1.1\r\nContent-Length: x\r\n\r\n", ErrInvalidContentLength},
+		{"POST / HTTP/1.1\r\nContent-Length: 5\r\nContent-Length: 7\r\n\r\n", ErrAmbiguousFraming},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\n\r\n", ErrUnsupportedTransferCoding},
+		{"POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n", ErrMalformedChunk},
+		{"GET / HTTP/1.1\r\n\r\n", ErrInvalidHost},
+		{"GET / HTTP/1.1\r\nHost: localhost", ErrIncompleteRequest},
+	}
+	for _, tt := range tests {
+		// Test: Errors wrap a typed error carrying the status
+		_, err := RequestFromReader(strings.NewReader(tt.data))
+		require.ErrorIs(t, err, tt.target)
+		var reqErr *Error
+		require.ErrorAs(t, err, &reqErr)
+		assert.Equal(t, tt.target.Status, reqErr.StatusCode())
+	}
+	assert.Equal(t, 505, ErrUnsupportedVersion.StatusCode())
+}
@@ -0,0 +1,320 @@
+package request
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TargetForm is one of the four forms of request-target defined by RFC 9112.
+type TargetForm int
+
+const (
+	OriginForm    TargetForm = iota // "/path?query", used by most requests
+	AbsoluteForm                    // "http://host/path?query", sent to proxies
+	AuthorityForm                   // "host:port", only for CONNECT
+	AsteriskForm                    // "*", only for OPTIONS
+)
+
+func (f TargetForm) String() string {
+	switch f {
+	case OriginForm:
+		return "origin-form"
+	case AbsoluteForm:
+		return "absolute-form"
+	case AuthorityForm:
+		return "authority-form"
+	case AsteriskForm:
+		return "asterisk-form"
+	default:
+		return "unknown"
+	}
+}
+
+// Target is a parsed request-target.
+type Target struct {
+	Form TargetForm
+
+	// Scheme and Authority are set for absolute-form targets, and Authority
+	// alone for authority-form targets.
+	Scheme    string
+	Authority string
+
+	// Path is the percent-decoded path and RawPath the path as sent. Both
+	// are empty for authority-form and asterisk-form targets.
+	Path    string
+	RawPath string
+
+	// Query holds the decoded query parameters and RawQuery the query as
+	// sent, without the leading "?".
+	Query    Query
+	RawQuery string
+
+	// Fragment is the decoded fragment. Clients should not send one, but it
+	// is stripped from the path rather than rejected.
+	Fragment string
+
+	segments []string
+}
+
+// Segments returns the decoded path segments, without the empty segments
+// produced by leading and trailing slashes. A segment may contain a "/" that
+// was sent percent-encoded.
+func (t Target) Segments() []string {
+	return t.segments
+}
+
+// Query maps query parameter names to their values in the order they were
+// sent.
+type Query map[string][]string
+
+// Get returns the first value for key, or "" if there is none.
+func (q Query) Get(key string) string {
+	if values := q[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Has reports whether key was sent, possibly without a value.
+func (q Query) Has(key string) bool {
+	_, ok := q[key]
+	return ok
+}
+
+// parseTarget parses the request-target sent with method.
+func parseTarget(method, target string) (*Target, error) {
+	for i := 0; i < len(target); i++ {
+		if target[i] <= ' ' || target[i] >= 0x7f {
+			return nil, fmt.Errorf("%w: invalid character %q", ErrInvalidTarget, target[i])
+		}
+	}
+
+	switch {
+	case method == "CONNECT":
+		if !validAuthority(target) {
+			return nil, fmt.Errorf("%w: CONNECT needs host:port, got %s", ErrInvalidTarget, target)
+		}
+		return &Target{Form: AuthorityForm, Authority: target, Query: Query{}}, nil
+	case target == "*":
+		if method != "OPTIONS" {
+			return nil, fmt.Errorf("%w: * is only allowed for OPTIONS", ErrInvalidTarget)
+		}
+		return &Target{Form: AsteriskForm, Query: Query{}}, nil
+	case strings.HasPrefix(target, "/"):
+		t := &Target{Form: OriginForm}
+		if err := t.parseOrigin(target); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+
+	scheme, rest, ok := strings.Cut(target, "://")
+	if !ok || !validScheme(scheme) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTarget, target)
+	}
+	end := strings.IndexAny(rest, "/?#")
+	if end == -1 {
+		end = len(rest)
+	}
+	t := &Target{
+		Form:      AbsoluteForm,
+		Scheme:    strings.ToLower(scheme),
+		Authority: rest[:end],
+	}
+	if t.Authority == "" {
+		return nil, fmt.Errorf("%w: missing authority in %s", ErrInvalidTarget, target)
+	}
+	origin := rest[end:]
+	if !strings.HasPrefix(origin, "/") {
+		origin = "/" + origin
+	}
+	if err := t.parseOrigin(origin); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parseOrigin fills in the path, query and fragment from an origin-form
+// target. Dot-dot segments are rejected so the path cannot climb above the
+// root. They are looked for in the decoded path, so that a ".." hidden behind
+// an encoded slash, as in "/a%2F..%2Fsecret", is caught as well.
+func (t *Target) parseOrigin(target string) error {
+	rest, fragment, _ := strings.Cut(target, "#")
+	rawPath, rawQuery, _ := strings.Cut(rest, "?")
+
+	var err error
+	if t.Path, err = unescape(rawPath, false); err != nil {
+		return err
+	}
+	for _, seg := range strings.Split(t.Path, "/") {
+		if seg == ".." {
+			return fmt.Errorf("%w: path traversal in %s", ErrInvalidTarget, rawPath)
+		}
+	}
+	if trimmed := strings.Trim(rawPath, "/"); trimmed != "" {
+		if err := t.parseSegments(trimmed); err != nil {
+			return err
+		}
+	}
+	t.RawPath = rawPath
+
+	if t.Query, err = parseQuery(rawQuery); err != nil {
+		return err
+	}
+	t.RawQuery = rawQuery
+
+	if t.Fragment, err = unescape(fragment, false); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseSegments decodes the "/"-separated segments of a path without its
+// leading and trailing slashes.
+func (t *Target) parseSegments(path string) error {
+	for _, raw := range strings.Split(path, "/") {
+		seg, err := unescape(raw, false)
+		if err != nil {
+			return err
+		}
+		t.segments = append(t.segments, seg)
+	}
+	return nil
+}
+
+// parseQuery decodes a query string of "&"-separated name=value pairs, where
+// "+" stands for a space.
+func parseQuery(rawQuery string) (Query, error) {
+	query := Query{}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, err := unescape(rawKey, true)
+		if err != nil {
+			return nil, err
+		}
+		value, err := unescape(rawValue, true)
+		if err != nil {
+			return nil, err
+		}
+		query[key] = append(query[key], value)
+	}
+	return query, nil
+}
+
+// unescape decodes the percent-encoded octets in s, and "+" as a space if
+// plusSpace is set. Truncated or non-hex escapes and encoded NULs are
+// rejected.
+func unescape(s string, plusSpace bool) (string, error) {
+	if !strings.ContainsAny(s, "%+") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return "", fmt.Errorf("%w: invalid percent-encoding in %s", ErrInvalidTarget, s)
+			}
+			octet := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if octet == 0 {
+				return "", fmt.Errorf("%w: encoded NUL in %s", ErrInvalidTarget, s)
+			}
+			b.WriteByte(octet)
+			i += 2
+		case c == '+' && plusSpace:
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// validScheme reports whether scheme is ALPHA *( ALPHA / DIGIT / "+" / "-" / "." ).
+func validScheme(scheme string) bool {
+	if scheme == "" {
+		return false
+	}
+	for i, c := range scheme {
+		letter := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+		if i == 0 && !letter {
+			return false
+		}
+		if !letter && !('0' <= c && c <= '9') && c != '+' && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// validAuthority reports whether authority has the host:port form required
+// for CONNECT.
+func validAuthority(authority string) bool {
+	host, port, ok := cutPort(authority)
+	return ok && host != "" && port != "" && validHost(authority)
+}
+
+// validHost reports whether host is a uri-host with an optional port: a
+// registered name or IPv4 address, or an IPv6 address in brackets. The empty
+// string is valid.
+func validHost(host string) bool {
+	name, port, _ := cutPort(host)
+	for _, c := range port {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	if ip, ok := strings.CutPrefix(name, "["); ok {
+		ip, ok = strings.CutSuffix(ip, "]")
+		if !ok || ip == "" {
+			return false
+		}
+		for _, c := range ip {
+			if !isHex(byte(c)) && c != ':' && c != '.' {
+				return false
+			}
+		}
+		return true
+	}
+	if name == "" {
+		return port == ""
+	}
+	for _, c := range name {
+		letter := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+		if !letter && !('0' <= c && c <= '9') && c != '-' && c != '.' && c != '_' && c != '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// cutPort splits host into the host and the port after the last colon, if
+// that colon is not part of a bracketed IPv6 address.
+func cutPort(host string) (name, port string, found bool) {
+	i := strings.LastIndex(host, ":")
+	if i == -1 || strings.LastIndex(host, "]") > i {
+		return host, "", false
+	}
+	return host[:i], host[i+1:], true
+}
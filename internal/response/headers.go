@@ -3,23 +3,90 @@ package response
 import (
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 
 	"github.com/evanwiseman/httpfromtcp/internal/headers"
 )
 
-func GetDefaultHeaders(contentLen int) headers.Headers {
+func GetDefaultHeaders(contentLen int) *headers.Headers {
 	h := headers.NewHeaders()
 	h.Set("Content-Length", fmt.Sprint(contentLen))
-	h.Set("Connection", "close")
 	h.Set("Content-Type", "text/html")
 	return h
 }
 
-func WriteHeaders(w io.Writer, headers headers.Headers) error {
-	for key, value := range headers {
-		_, err := w.Write([]byte(key + ": " + value + "\r\n"))
-		if err != nil {
-			return err
+// HeaderOrder selects the order in which header fields are serialized.
+type HeaderOrder int
+
+const (
+	// InsertionOrder writes fields in the order they were first added.
+	InsertionOrder HeaderOrder = iota
+	// CanonicalOrder writes Date, then Server, then all Content-* fields,
+	// then every other field, sorting the fields within each group by name.
+	// The output does not depend on the order fields were added in.
+	CanonicalOrder
+)
+
+// InvalidHeaderError is returned when a header or trailer field cannot be
+// written safely: its name is not a token, or its value contains CR, LF or
+// NUL and could be used to inject extra fields or a second response.
+type InvalidHeaderError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidHeaderError) Error() string {
+	return fmt.Sprintf("response: invalid header %q: %s", e.Name, e.Reason)
+}
+
+// validateHeaders checks every field of h before anything is written, so an
+// invalid field never leaves a partial header section on the wire.
+func validateHeaders(h *headers.Headers) error {
+	for name, value := range h.All() {
+		if !headers.ValidName(name) {
+			return &InvalidHeaderError{Name: name, Reason: "name is not a valid token"}
+		}
+		if !headers.ValidValue(value) {
+			return &InvalidHeaderError{Name: name, Reason: "value contains CR, LF or NUL"}
+		}
+	}
+	return nil
+}
+
+// WriteHeaders writes the header fields in insertion order with canonical
+// name casing, one line per value, followed by the blank line ending the
+// section.
+func WriteHeaders(w io.Writer, h *headers.Headers) error {
+	return WriteHeadersInOrder(w, h, InsertionOrder)
+}
+
+// WriteHeadersInOrder is like WriteHeaders but writes the fields in the given
+// order. The values of a field always keep the order they were added in.
+// Nothing is written if a field is invalid; an *InvalidHeaderError is
+// returned instead.
+func WriteHeadersInOrder(w io.Writer, h *headers.Headers, order HeaderOrder) error {
+	if err := validateHeaders(h); err != nil {
+		return err
+	}
+
+	names := h.Names()
+	if order == CanonicalOrder {
+		slices.SortFunc(names, func(a, b string) int {
+			a, b = headers.CanonicalName(a), headers.CanonicalName(b)
+			if rankA, rankB := canonicalRank(a), canonicalRank(b); rankA != rankB {
+				return rankA - rankB
+			}
+			return strings.Compare(a, b)
+		})
+	}
+
+	for _, name := range names {
+		for _, value := range h.Values(name) {
+			_, err := w.Write([]byte(headers.CanonicalName(name) + ": " + value + "\r\n"))
+			if err != nil {
+				return err
+			}
 		}
 	}
 	_, err := w.Write([]byte("\r\n"))
@@ -28,3 +95,16 @@ func WriteHeaders(w io.Writer, headers headers.Headers) error {
 	}
 	return nil
 }
+
+func canonicalRank(name string) int {
+	switch {
+	case name == "Date":
+		return 0
+	case name == "Server":
+		return 1
+	case strings.HasPrefix(name, "Content-"):
+		return 2
+	default:
+		return 3
+	}
+}
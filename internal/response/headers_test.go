@@ -0,0 +1,90 @@
+package response
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/evanwiseman/httpfromtcp/internal/headers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHeadersOrder(t *testing.T) {
+	h := headers.NewHeaders()
+	h.Set("x-trace", "1")
+	h.Set("content-type", "text/html")
+	h.Add("Set-Cookie", "a=1")
+	h.Set("server", "httpfromtcp")
+	h.Add("set-cookie", "b=2")
+	h.Set("Date", "Sun, 18 Oct 2026 00:00:00 GMT")
+	h.Set("CONTENT-LENGTH", "0")
+
+	// Test: Insertion order with canonical casing
+	var buf bytes.Buffer
+	require.NoError(t, WriteHeaders(&buf, h))
+	assert.Equal(t, "X-Trace: 1\r\n"+
+		"Content-Type: text/html\r\n"+
+		"Set-Cookie: a=1\r\n"+
+		"Set-Cookie: b=2\r\n"+
+		"Server: httpfromtcp\r\n"+
+		"Date: Sun, 18 Oct 2026 00:00:00 GMT\r\n"+
+		"Content-Length: 0\r\n"+
+		"\r\n", buf.String())
+
+	// Test: Canonical order
+	buf.Reset()
+	require.NoError(t, WriteHeadersInOrder(&buf, h, CanonicalOrder))
+	assert.Equal(t, "Date: Sun, 18 Oct 2026 00:00:00 GMT\r\n"+
+		"Server: httpfromtcp\r\n"+
+		"Content-Length: 0\r\n"+
+		"Content-Type: text/html\r\n"+
+		"Set-Cookie: a=1\r\n"+
+		"Set-Cookie: b=2\r\n"+
+		"X-Trace: 1\r\n"+
+		"\r\n", buf.String())
+
+	// Test: Output is stable across repeated writes
+	for range 10 {
+		var again bytes.Buffer
+		require.NoError(t, WriteHeadersInOrder(&again, h, CanonicalOrder))
+		assert.Equal(t, buf.String(), again.String())
+	}
+}
+
+func TestWriteHeadersInjection(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"X-Echo", "ok\r\nSet-Cookie: admin=1"},
+		{"X-Echo", "ok\n\nHTTP/1.1 200 OK"},
+		{"X-Echo", "ok\x00"},
+		{"X-Bad Name", "ok"},
+		{"X-Bad:Name", "ok"},
+		{"", "ok"},
+	}
+	for _, tt := range tests {
+		// Test: Nothing is written and the error is typed
+		h := headers.NewHeaders()
+		h.Set("Content-Length", "0")
+		h.Set(tt.name, tt.value)
+		var buf bytes.Buffer
+		err := WriteHeaders(&buf, h)
+		var headerErr *InvalidHeaderError
+		require.ErrorAs(t, err, &headerErr)
+		assert.Equal(t, tt.name, headerErr.Name)
+		assert.Empty(t, buf.String())
+	}
+
+	// Test: Writer rejects the headers and can retry
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	h := headers.NewHeaders()
+	h.Set("X-Echo", "a\r\nb")
+	var headerErr *InvalidHeaderError
+	require.ErrorAs(t, w.WriteHeaders(h), &headerErr)
+	h.Set("X-Echo", "ab")
+	require.NoError(t, w.WriteHeaders(h))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nX-Echo: ab\r\n\r\n", buf.String())
+}
@@ -1,37 +1,198 @@
 package response
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 type StatusCode int
 
+// Status codes from the IANA HTTP Status Code Registry.
 const (
-	StatusOk                  = 200
-	StatusBadRequest          = 400
-	StatusInternalServerError = 500
+	StatusContinue           StatusCode = 100
+	StatusSwitchingProtocols StatusCode = 101
+	StatusProcessing         StatusCode = 102
+	StatusEarlyHints         StatusCode = 103
+
+	StatusOk                   StatusCode = 200
+	StatusCreated              StatusCode = 201
+	StatusAccepted             StatusCode = 202
+	StatusNonAuthoritativeInfo StatusCode = 203
+	StatusNoContent            StatusCode = 204
+	StatusResetContent         StatusCode = 205
+	StatusPartialContent       StatusCode = 206
+	StatusMultiStatus          StatusCode = 207
+	StatusAlreadyReported      StatusCode = 208
+	StatusIMUsed               StatusCode = 226
+
+	StatusMultipleChoices   StatusCode = 300
+	StatusMovedPermanently  StatusCode = 301
+	StatusFound             StatusCode = 302
+	StatusSeeOther          StatusCode = 303
+	StatusNotModified       StatusCode = 304
+	StatusUseProxy          StatusCode = 305
+	StatusTemporaryRedirect StatusCode = 307
+	StatusPermanentRedirect StatusCode = 308
+
+	StatusBadRequest                   StatusCode = 400
+	StatusUnauthorized                 StatusCode = 401
+	StatusPaymentRequired              StatusCode = 402
+	StatusForbidden                    StatusCode = 403
+	StatusNotFound                     StatusCode = 404
+	StatusMethodNotAllowed             StatusCode = 405
+	StatusNotAcceptable                StatusCode = 406
+	StatusProxyAuthRequired            StatusCode = 407
+	StatusRequestTimeout               StatusCode = 408
+	StatusConflict                     StatusCode = 409
+	StatusGone                         StatusCode = 410
+	StatusLengthRequired               StatusCode = 411
+	StatusPreconditionFailed           StatusCode = 412
+	StatusRequestEntityTooLarge        StatusCode = 413
+	StatusRequestURITooLong            StatusCode = 414
+	StatusUnsupportedMediaType         StatusCode = 415
+	StatusRequestedRangeNotSatisfiable StatusCode = 416
+	StatusExpectationFailed            StatusCode = 417
+	StatusMisdirectedRequest           StatusCode = 421
+	StatusUnprocessableEntity          StatusCode = 422
+	StatusLocked                       StatusCode = 423
+	StatusFailedDependency             StatusCode = 424
+	StatusTooEarly                     StatusCode = 425
+	StatusUpgradeRequired              StatusCode = 426
+	StatusPreconditionRequired         StatusCode = 428
+	StatusTooManyRequests              StatusCode = 429
+	StatusRequestHeaderFieldsTooLarge  StatusCode = 431
+	StatusUnavailableForLegalReasons   StatusCode = 451
+
+	StatusInternalServerError           StatusCode = 500
+	StatusNotImplemented                StatusCode = 501
+	StatusBadGateway                    StatusCode = 502
+	StatusServiceUnavailable            StatusCode = 503
+	StatusGatewayTimeout                StatusCode = 504
+	StatusHTTPVersionNotSupported       StatusCode = 505
+	StatusVariantAlsoNegotiates         StatusCode = 506
+	StatusInsufficientStorage           StatusCode = 507
+	StatusLoopDetected                  StatusCode = 508
+	StatusNotExtended                   StatusCode = 510
+	StatusNetworkAuthenticationRequired StatusCode = 511
 )
 
+var statusText = map[StatusCode]string{
+	StatusContinue:           "Continue",
+	StatusSwitchingProtocols: "Switching Protocols",
+	StatusProcessing:         "Processing",
+	StatusEarlyHints:         "Early Hints",
+
+	StatusOk:                   "OK",
+	StatusCreated:              "Created",
+	StatusAccepted:             "Accepted",
+	StatusNonAuthoritativeInfo: "Non-Authoritative Information",
+	StatusNoContent:            "No Content",
+	StatusResetContent:         "Reset Content",
+	StatusPartialContent:       "Partial Content",
+	StatusMultiStatus:          "Multi-Status",
+	StatusAlreadyReported:      "Already Reported",
+	StatusIMUsed:               "IM Used",
+
+	StatusMultipleChoices:   "Multiple Choices",
+	StatusMovedPermanently:  "Moved Permanently",
+	StatusFound:             "Found",
+	StatusSeeOther:          "See Other",
+	StatusNotModified:       "Not Modified",
+	StatusUseProxy:          "Use Proxy",
+	StatusTemporaryRedirect: "Temporary Redirect",
+	StatusPermanentRedirect: "Permanent Redirect",
+
+	StatusBadRequest:                   "Bad Request",
+	StatusUnauthorized:                 "Unauthorized",
+	StatusPaymentRequired:              "Payment Required",
+	StatusForbidden:                    "Forbidden",
+	StatusNotFound:                     "Not Found",
+	StatusMethodNotAllowed:             "Method Not Allowed",
+	StatusNotAcceptable:                "Not Acceptable",
+	StatusProxyAuthRequired:            "Proxy Authentication Required",
+	StatusRequestTimeout:               "Request Timeout",
+	StatusConflict:                     "Conflict",
+	StatusGone:                         "Gone",
+	StatusLengthRequired:               "Length Required",
+	StatusPreconditionFailed:           "Precondition Failed",
+	StatusRequestEntityTooLarge:        "Content Too Large",
+	StatusRequestURITooLong:            "URI Too Long",
+	StatusUnsupportedMediaType:         "Unsupported Media Type",
+	StatusRequestedRangeNotSatisfiable: "Range Not Satisfiable",
+	StatusExpectationFailed:            "Expectation Failed",
+	StatusMisdirectedRequest:           "Misdirected Request",
+	StatusUnprocessableEntity:          "Unprocessable Content",
+	StatusLocked:                       "Locked",
+	StatusFailedDependency:             "Failed Dependency",
+	StatusTooEarly:                     "Too Early",
+	StatusUpgradeRequired:              "Upgrade Required",
+	StatusPreconditionRequired:         "Precondition Required",
+	StatusTooManyRequests:              "Too Many Requests",
+	StatusRequestHeaderFieldsTooLarge:  "Request Header Fields Too Large",
+	StatusUnavailableForLegalReasons:   "Unavailable For Legal Reasons",
+
+	StatusInternalServerError:           "Internal Server Error",
+	StatusNotImplemented:                "Not Implemented",
+	StatusBadGateway:                    "Bad Gateway",
+	StatusServiceUnavailable:            "Service Unavailable",
+	StatusGatewayTimeout:                "Gateway Timeout",
+	StatusHTTPVersionNotSupported:       "HTTP Version Not Supported",
+	StatusVariantAlsoNegotiates:         "Variant Also Negotiates",
+	StatusInsufficientStorage:           "Insufficient Storage",
+	StatusLoopDetected:                  "Loop Detected",
+	StatusNotExtended:                   "Not Extended",
+	StatusNetworkAuthenticationRequired: "Network Authentication Required",
+}
+
+// ErrInvalidStatus is returned when a status code is not three digits or a
+// reason phrase contains CR or LF.
+var ErrInvalidStatus = errors.New("response: invalid status")
+
+// StatusText returns the standard reason phrase for statusCode, or "" if the
+// code is not registered.
+func StatusText(statusCode StatusCode) string {
+	return statusText[statusCode]
+}
+
+// GetStatusLine returns the status line for statusCode with its standard
+// reason phrase. Unregistered codes get an empty reason phrase.
 func GetStatusLine(statusCode StatusCode) []byte {
-	var reason string
-	switch statusCode {
-	case StatusOk:
-		reason = "OK"
-	case StatusBadRequest:
-		reason = "BAD REQUEST"
-	case StatusInternalServerError:
-		reason = "INTERNAL SERVER ERROR"
-	}
+	return GetStatusLineWithReason(statusCode, StatusText(statusCode))
+}
 
+// GetStatusLineWithReason returns the status line for statusCode with a
+// custom reason phrase.
+func GetStatusLineWithReason(statusCode StatusCode, reason string) []byte {
 	return []byte(fmt.Sprintf("HTTP/1.1 %d %s\r\n", statusCode, reason))
 }
 
 func WriteStatusLine(w io.Writer, statusCode StatusCode) error {
+	return WriteStatusLineWithReason(w, statusCode, StatusText(statusCode))
+}
+
+// WriteStatusLineWithReason writes the status line for statusCode with a
+// custom reason phrase, after checking that the code has three digits and
+// the reason fits on one line.
+func WriteStatusLineWithReason(w io.Writer, statusCode StatusCode, reason string) error {
+	if err := validateStatus(statusCode, reason); err != nil {
+		return err
+	}
 
-	_, err := w.Write(GetStatusLine(statusCode))
+	_, err := w.Write(GetStatusLineWithReason(statusCode, reason))
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func validateStatus(statusCode StatusCode, reason string) error {
+	if statusCode < 100 || statusCode > 999 {
+		return fmt.Errorf("%w: status code %d is not three digits", ErrInvalidStatus, statusCode)
+	}
+	if strings.ContainsAny(reason, "\r\n\x00") {
+		return fmt.Errorf("%w: reason phrase %q contains CR, LF or NUL", ErrInvalidStatus, reason)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package response
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusLine(t *testing.T) {
+	// Test: Standard reason phrases
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n", string(GetStatusLine(StatusOk)))
+	assert.Equal(t, "HTTP/1.1 400 Bad Request\r\n", string(GetStatusLine(StatusBadRequest)))
+	assert.Equal(t, "HTTP/1.1 103 Early Hints\r\n", string(GetStatusLine(StatusEarlyHints)))
+	assert.Equal(t, "HTTP/1.1 511 Network Authentication Required\r\n", string(GetStatusLine(StatusNetworkAuthenticationRequired)))
+	assert.Equal(t, "", StatusText(418))
+	assert.Equal(t, "HTTP/1.1 599 \r\n", string(GetStatusLine(599)))
+
+	// Test: Custom reason phrase
+	var buf bytes.Buffer
+	require.NoError(t, WriteStatusLineWithReason(&buf, StatusOk, "Fine"))
+	assert.Equal(t, "HTTP/1.1 200 Fine\r\n", buf.String())
+
+	// Test: Invalid status codes and reasons
+	for _, code := range []StatusCode{0, 99, 1000, -200} {
+		buf.Reset()
+		assert.ErrorIs(t, WriteStatusLine(&buf, code), ErrInvalidStatus)
+		assert.Empty(t, buf.String())
+	}
+	assert.ErrorIs(t, WriteStatusLineWithReason(&buf, StatusOk, "OK\r\nX-Injected: 1"), ErrInvalidStatus)
+
+	// Test: Writer with a custom reason
+	buf.Reset()
+	w := NewWriter(&buf)
+	assert.ErrorIs(t, w.WriteStatusLine(42), ErrInvalidStatus)
+	require.NoError(t, w.WriteStatusLineWithReason(StatusNotFound, "Nothing Here"))
+	assert.Equal(t, StatusNotFound, w.StatusCode())
+	assert.Equal(t, "HTTP/1.1 404 Nothing Here\r\n", buf.String())
+}
@@ -0,0 +1,78 @@
+package response
+
+import (
+	"strings"
+
+	"github.com/evanwiseman/httpfromtcp/internal/headers"
+)
+
+// forbiddenTrailers are fields that must not be sent in a trailer section
+// because recipients need them before the content: message framing, routing,
+// authentication, response control and content processing fields
+// (RFC 9110, section 6.5.1).
+var forbiddenTrailers = map[string]bool{
+	"Transfer-Encoding":   true,
+	"Content-Length":      true,
+	"Content-Type":        true,
+	"Content-Encoding":    true,
+	"Content-Range":       true,
+	"Host":                true,
+	"Trailer":             true,
+	"Te":                  true,
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Upgrade":             true,
+	"Authorization":       true,
+	"Proxy-Authenticate":  true,
+	"Www-Authenticate":    true,
+	"Set-Cookie":          true,
+	"Cache-Control":       true,
+	"Expires":             true,
+	"Age":                 true,
+	"Date":                true,
+	"Location":            true,
+	"Retry-After":         true,
+	"Vary":                true,
+	"Max-Forwards":        true,
+	"Proxy-Authorization": true,
+}
+
+// declaredTrailers returns the field names announced in the Trailer header
+// of h, rejecting names that are not allowed in trailers.
+func declaredTrailers(h *headers.Headers) ([]string, error) {
+	var names []string
+	for _, value := range h.Values("trailer") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if forbiddenTrailers[headers.CanonicalName(name)] {
+				return nil, &InvalidHeaderError{Name: "Trailer", Reason: name + " is not allowed in trailers"}
+			}
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// validateTrailers checks that every field of h was announced in the
+// Trailer header.
+func validateTrailers(h *headers.Headers, declared []string) error {
+	for _, name := range h.Names() {
+		if forbiddenTrailers[headers.CanonicalName(name)] {
+			return &InvalidHeaderError{Name: name, Reason: "field is not allowed in trailers"}
+		}
+		found := false
+		for _, d := range declared {
+			if strings.EqualFold(d, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &InvalidHeaderError{Name: name, Reason: "trailer was not declared in the Trailer header"}
+		}
+	}
+	return nil
+}
@@ -1,41 +1,348 @@
 package response
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"net"
 
 	"github.com/evanwiseman/httpfromtcp/internal/headers"
 )
 
+// ErrWriteOrder is returned when a response part is written out of order,
+// for example a second status line or a plain body after chunked headers.
+var ErrWriteOrder = errors.New("response: invalid write order")
+
+// bufferSize is how much body Write buffers before the response switches
+// to chunked encoding.
+const bufferSize = 4 << 10
+
+type writerState int
+
+const (
+	writerStatusLine writerState = iota // nothing written yet
+	writerHeaders                       // status line written
+	writerBody                          // headers written
+	writerTrailers                      // chunked body terminated
+	writerDone                          // trailers written
+)
+
+func (s writerState) String() string {
+	switch s {
+	case writerStatusLine:
+		return "status line"
+	case writerHeaders:
+		return "headers"
+	case writerBody:
+		return "body"
+	case writerTrailers:
+		return "trailers"
+	case writerDone:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+// Writer writes a response in order: status line, headers, then either a
+// plain body or a chunked body followed by optional trailers. Out of order
+// calls return an error wrapping ErrWriteOrder. Writing headers or a body
+// before the status line implicitly sends a 200 status line, and writing a
+// body before the headers implicitly sends default headers.
+//
+// Writer also implements io.Writer. Bodies written with Write are buffered:
+// if the handler returns before the buffer fills up, Finish sends the
+// response with a computed Content-Length, otherwise the response switches
+// to chunked encoding once the buffer overflows or Flush is called.
 type Writer struct {
-	writer io.Writer
+	writer    io.Writer
+	body      io.Writer // receives the body, chunk framing and trailers
+	closeConn bool
+	http10    bool
+	unframed  bool // chunked body sent without framing to an HTTP/1.0 client
+
+	headerOrder    HeaderOrder
+	state          writerState
+	chunked        bool
+	trailers       []string // declared in the Trailer header
+	header         *headers.Headers
+	buf            []byte
+	statusCode     StatusCode
+	bodyBytes      int
+	onWriteHeaders []func(*headers.Headers)
+	onFinish       []func()
 }
 
-func NewWriter(conn net.Conn) *Writer {
+func NewWriter(w io.Writer) *Writer {
 	return &Writer{
-		writer: conn,
+		writer: w,
+		body:   w,
 	}
 }
 
 func (w *Writer) WriteStatusLine(statusCode StatusCode) error {
-	if err := WriteStatusLine(w.writer, statusCode); err != nil {
+	return w.WriteStatusLineWithReason(statusCode, StatusText(statusCode))
+}
+
+// WriteStatusLineWithReason writes the status line with a custom reason
+// phrase instead of the standard one.
+func (w *Writer) WriteStatusLineWithReason(statusCode StatusCode, reason string) error {
+	if w.state != writerStatusLine {
+		return w.orderError("WriteStatusLine")
+	}
+	if err := WriteStatusLineWithReason(w.writer, statusCode, reason); err != nil {
 		return err
 	}
+	w.statusCode = statusCode
+	w.state = writerHeaders
 
 	return nil
 }
 
-func (w *Writer) WriteHeaders(headers headers.Headers) error {
-	if err := WriteHeaders(w.writer, headers); err != nil {
+// WriteInformational writes an interim 1xx response, such as 100 Continue or
+// 103 Early Hints, ahead of the final response. h may be nil. Interim
+// responses are sent even when the body is omitted, but never to an HTTP/1.0
+// client, for which WriteInformational does nothing.
+func (w *Writer) WriteInformational(statusCode StatusCode, h *headers.Headers) error {
+	if w.state != writerStatusLine {
+		return w.orderError("WriteInformational")
+	}
+	if statusCode < 100 || statusCode > 199 || statusCode == StatusSwitchingProtocols {
+		return fmt.Errorf("%w: %d is not an informational status", ErrInvalidStatus, statusCode)
+	}
+	if w.http10 {
+		return nil
+	}
+	if h == nil {
+		h = headers.NewHeaders()
+	}
+	if err := validateHeaders(h); err != nil {
 		return err
 	}
 
-	return nil
+	if err := WriteStatusLine(w.writer, statusCode); err != nil {
+		return err
+	}
+	return WriteHeadersInOrder(w.writer, h, w.headerOrder)
+}
+
+// StatusCode returns the status written with WriteStatusLine, or 0 if no
+// status line has been written yet.
+func (w *Writer) StatusCode() StatusCode {
+	return w.statusCode
+}
+
+// BodyBytes returns the number of body bytes written so far, excluding chunk
+// framing for chunked bodies.
+func (w *Writer) BodyBytes() int {
+	return w.bodyBytes
+}
+
+// OnWriteHeaders registers fn to be called with the response headers just
+// before they are written, letting middleware add or adjust headers set by
+// the handler. Callbacks run in registration order.
+func (w *Writer) OnWriteHeaders(fn func(*headers.Headers)) {
+	w.onWriteHeaders = append(w.onWriteHeaders, fn)
+}
+
+// OnFinish registers fn to be called once Finish has completed the response,
+// when StatusCode and BodyBytes account for a body buffered by Write as well.
+// Callbacks run in registration order, even if completing the response
+// failed.
+func (w *Writer) OnFinish(fn func()) {
+	w.onFinish = append(w.onFinish, fn)
+}
+
+// OmitBody makes the writer drop the body, chunk framing and trailers while
+// still sending the status line and headers, as required when answering a
+// HEAD request. Headers, including a computed Content-Length, are the same as
+// for the equivalent GET response.
+func (w *Writer) OmitBody() {
+	w.body = io.Discard
 }
 
+// UseHTTP10 adapts the response for an HTTP/1.0 client, which understands
+// neither chunked encoding nor interim responses. A chunked body is sent
+// without chunk framing or trailers and delimited by closing the connection,
+// 1xx responses are dropped, and a persistent connection is announced with
+// "Connection: keep-alive".
+func (w *Writer) UseHTTP10() {
+	w.http10 = true
+}
+
+// SetHeaderOrder sets the order headers and trailers are serialized in. The
+// default is InsertionOrder.
+func (w *Writer) SetHeaderOrder(order HeaderOrder) {
+	w.headerOrder = order
+}
+
+// CloseConnection marks the connection to be closed once the response has
+// been written. A "Connection: close" header is added to the response headers.
+func (w *Writer) CloseConnection() {
+	w.closeConn = true
+}
+
+// ClosesConnection reports whether the connection should be closed after this
+// response, either because the server asked for it or the handler sent a
+// "Connection: close" header.
+func (w *Writer) ClosesConnection() bool {
+	return w.closeConn
+}
+
+// WriteHeaders writes the response headers. A "Transfer-Encoding: chunked"
+// header selects a chunked body, otherwise the body is written with
+// WriteBody. A body already buffered by Write follows the headers straight
+// away. For an HTTP/1.0 client, the Transfer-Encoding and Trailer
+// headers of a chunked response are dropped and the connection is closed
+// after the body instead.
+func (w *Writer) WriteHeaders(headers *headers.Headers) error {
+	if w.state == writerStatusLine {
+		if err := w.WriteStatusLine(StatusOk); err != nil {
+			return err
+		}
+	}
+	if w.state != writerHeaders {
+		return w.orderError("WriteHeaders")
+	}
+
+	for _, fn := range w.onWriteHeaders {
+		fn(headers)
+	}
+	trailers, err := declaredTrailers(headers)
+	if err != nil {
+		return err
+	}
+	chunked := headers.HasToken("transfer-encoding", "chunked")
+	if chunked && w.http10 {
+		headers.Del("Transfer-Encoding")
+		headers.Del("Trailer")
+		w.unframed = true
+		w.closeConn = true
+	}
+	if w.closeConn {
+		headers.Set("Connection", "close")
+	} else if headers.HasToken("connection", "close") {
+		w.closeConn = true
+	} else if w.http10 {
+		headers.Set("Connection", "keep-alive")
+	}
+
+	if err := WriteHeadersInOrder(w.writer, headers, w.headerOrder); err != nil {
+		return err
+	}
+	w.chunked = chunked
+	w.trailers = trailers
+	w.state = writerBody
+
+	return w.flushBuffer()
+}
+
+// flushBuffer writes the body buffered by Write using the framing selected by
+// the headers.
+func (w *Writer) flushBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	if w.chunked {
+		_, err := w.WriteChunkedBody(buf)
+		return err
+	}
+	_, err := w.writeBody(buf)
+	return err
+}
+
+// Header returns the headers sent with a body written through Write. They
+// are written when the response is committed by Finish, Flush or a full
+// buffer, and are not used by WriteHeaders.
+func (w *Writer) Header() *headers.Headers {
+	if w.header == nil {
+		w.header = headers.NewHeaders()
+	}
+	return w.header
+}
+
+// Write writes p as part of the body. Before the headers are written, p is
+// buffered; afterwards it is written straight away using the body framing
+// the headers selected.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.state >= writerBody {
+		if w.chunked {
+			return w.WriteChunkedBody(p)
+		}
+		return w.WriteBody(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > bufferSize {
+		if err := w.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Reset drops the body buffered by Write and the fields set in Header. It
+// has no effect on parts of the response that were already written.
+func (w *Writer) Reset() {
+	w.buf = nil
+	w.header = nil
+}
+
+// Flush commits the response if it has not been committed yet and writes any
+// buffered body. The response is sent chunked unless the handler set a
+// Content-Length in Header, or close-delimited to an HTTP/1.0 client.
+func (w *Writer) Flush() error {
+	if w.state < writerBody {
+		h := w.Header()
+		if bodylessStatus(w.statusCode) {
+			w.buf = nil
+		} else if _, ok := h.Get("content-length"); !ok {
+			h.Set("Transfer-Encoding", "chunked")
+		}
+		if err := w.commit(h); err != nil {
+			return err
+		}
+	}
+	return w.flushBuffer()
+}
+
+// commit writes the status line, if needed, and the headers h for a body
+// written through Write.
+func (w *Writer) commit(h *headers.Headers) error {
+	if w.state == writerStatusLine {
+		if err := w.WriteStatusLine(StatusOk); err != nil {
+			return err
+		}
+	}
+	if _, ok := h.Get("content-type"); !ok && len(w.buf) > 0 {
+		h.Set("Content-Type", "text/html")
+	}
+	return w.WriteHeaders(h)
+}
+
+// WriteBody writes p as part of a plain body. If no headers have been
+// written, default headers without a Content-Length are sent and the
+// connection is closed after the response to delimit the body.
 func (w *Writer) WriteBody(p []byte) (int, error) {
-	n, err := w.writer.Write(p)
+	if w.state < writerBody {
+		w.CloseConnection()
+		h := GetDefaultHeaders(0)
+		h.Del("Content-Length")
+		if err := w.WriteHeaders(h); err != nil {
+			return 0, err
+		}
+	}
+	if w.state != writerBody || w.chunked {
+		return 0, w.orderError("WriteBody")
+	}
+
+	return w.writeBody(p)
+}
+
+func (w *Writer) writeBody(p []byte) (int, error) {
+	n, err := w.body.Write(p)
+	w.bodyBytes += n
 	if err != nil {
 		return 0, err
 	}
@@ -43,16 +350,143 @@ func (w *Writer) WriteBody(p []byte) (int, error) {
 	return n, nil
 }
 
+// WriteChunkedBody writes p as a single chunk. If no headers have been
+// written, default headers with "Transfer-Encoding: chunked" are sent.
 func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
-	numBytes := len(p)
-	return w.WriteBody([]byte(fmt.Sprintf("%X\r\n%s\r\n", numBytes, p)))
+	if w.state < writerBody {
+		h := GetDefaultHeaders(0)
+		h.Del("Content-Length")
+		h.Set("Transfer-Encoding", "chunked")
+		if err := w.WriteHeaders(h); err != nil {
+			return 0, err
+		}
+	}
+	if w.state != writerBody || !w.chunked {
+		return 0, w.orderError("WriteChunkedBody")
+	}
+	if len(p) == 0 {
+		// An empty chunk would terminate the body
+		return 0, nil
+	}
+
+	if err := w.writeFraming(fmt.Sprintf("%X\r\n", len(p))); err != nil {
+		return 0, err
+	}
+	n, err := w.writeBody(p)
+	if err != nil {
+		return n, err
+	}
+	if err := w.writeFraming("\r\n"); err != nil {
+		return n, err
+	}
+	return n, nil
 }
 
+// writeFraming writes chunk framing, which is left out of a chunked body sent
+// to an HTTP/1.0 client.
+func (w *Writer) writeFraming(s string) error {
+	if w.unframed {
+		return nil
+	}
+	_, err := io.WriteString(w.body, s)
+	return err
+}
+
+// WriteChunkedBodyDone writes the last chunk. If the headers declared
+// trailers, the response is left open for WriteTrailers, otherwise the empty
+// trailer section ending the response is written as well.
 func (w *Writer) WriteChunkedBodyDone() error {
-	_, err := w.writer.Write([]byte("0\r\n"))
+	if w.state < writerBody {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if w.state != writerBody || !w.chunked {
+		return w.orderError("WriteChunkedBodyDone")
+	}
+	if err := w.writeFraming("0\r\n"); err != nil {
+		return err
+	}
+	w.state = writerTrailers
+	if len(w.trailers) == 0 {
+		return w.Finish()
+	}
+	return nil
+}
+
+// WriteTrailers writes the trailer section ending a chunked response. Every
+// field must have been declared in the Trailer header and must not be one of
+// the fields forbidden in trailers, such as Content-Length or Host.
+func (w *Writer) WriteTrailers(h *headers.Headers) error {
+	if w.state != writerTrailers {
+		return w.orderError("WriteTrailers")
+	}
+	if err := validateTrailers(h, w.trailers); err != nil {
+		return err
+	}
+	if !w.unframed {
+		if err := WriteHeadersInOrder(w.body, h, w.headerOrder); err != nil {
+			return err
+		}
+	}
+	w.state = writerDone
+	return nil
+}
+
+// Finish completes the response once the handler is done. A response that
+// has not been committed yet is sent with a Content-Length computed from the
+// buffered body (200 with an empty body if nothing was written at all), and a
+// chunked response is terminated with the last chunk and an empty trailer
+// section as needed. 1xx, 204 and 304 responses never have a body, so they
+// are sent without one and without an automatic Content-Length. The
+// callbacks registered with OnFinish run afterwards.
+func (w *Writer) Finish() error {
+	err := w.finish()
+	for _, fn := range w.onFinish {
+		fn()
+	}
+	w.onFinish = nil
 	return err
 }
 
-func (w *Writer) WriteTrailers(h headers.Headers) error {
-	return w.WriteHeaders(h)
+func (w *Writer) finish() error {
+	if w.state < writerBody {
+		h := w.Header()
+		if bodylessStatus(w.statusCode) {
+			w.buf = nil
+		} else if _, ok := h.Get("content-length"); !ok {
+			h.Set("Content-Length", fmt.Sprint(len(w.buf)))
+		}
+		if err := w.commit(h); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+	if w.state == writerBody && w.chunked {
+		if err := w.writeFraming("0\r\n"); err != nil {
+			return err
+		}
+		w.state = writerTrailers
+	}
+	if w.state == writerTrailers {
+		if err := w.writeFraming("\r\n"); err != nil {
+			return err
+		}
+		w.state = writerDone
+	}
+	return nil
+}
+
+// bodylessStatus reports whether a response with the status code must not
+// have a body.
+func bodylessStatus(code StatusCode) bool {
+	return code >= 100 && code < 200 || code == StatusNoContent || code == StatusNotModified
+}
+
+func (w *Writer) orderError(call string) error {
+	part := w.state.String()
+	if w.state == writerBody && w.chunked {
+		part = "chunked body"
+	}
+	return fmt.Errorf("%w: %s called while writing %s", ErrWriteOrder, call, part)
 }
@@ -0,0 +1,344 @@
+package response
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/evanwiseman/httpfromtcp/internal/headers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterOrder(t *testing.T) {
+	// Test: Plain response in order
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	h := headers.NewHeaders()
+	h.Set("Content-Length", "2")
+	require.NoError(t, w.WriteHeaders(h))
+	_, err := w.WriteBody([]byte("ok"))
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok", buf.String())
+
+	// Test: Repeated and out of order calls
+	assert.ErrorIs(t, w.WriteStatusLine(StatusOk), ErrWriteOrder)
+	assert.ErrorIs(t, w.WriteHeaders(headers.NewHeaders()), ErrWriteOrder)
+	_, err = w.WriteChunkedBody([]byte("x"))
+	assert.ErrorIs(t, err, ErrWriteOrder)
+	assert.ErrorIs(t, w.WriteChunkedBodyDone(), ErrWriteOrder)
+	assert.ErrorIs(t, w.WriteTrailers(headers.NewHeaders()), ErrWriteOrder)
+
+	// Test: Plain body after chunked headers
+	buf.Reset()
+	w = NewWriter(&buf)
+	h = headers.NewHeaders()
+	h.Set("Transfer-Encoding", "chunked")
+	require.NoError(t, w.WriteHeaders(h))
+	_, err = w.WriteBody([]byte("x"))
+	assert.ErrorIs(t, err, ErrWriteOrder)
+	assert.ErrorContains(t, err, "chunked body")
+	_, err = w.WriteChunkedBody([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.WriteChunkedBodyDone())
+	_, err = w.WriteChunkedBody([]byte("x"))
+	assert.ErrorIs(t, err, ErrWriteOrder)
+	assert.ErrorIs(t, w.WriteTrailers(headers.NewHeaders()), ErrWriteOrder)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n", buf.String())
+
+	// Test: Body before status line and headers
+	buf.Reset()
+	w = NewWriter(&buf)
+	_, err = w.WriteBody([]byte("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, StatusCode(StatusOk), w.StatusCode())
+	assert.True(t, w.ClosesConnection())
+	assert.Contains(t, buf.String(), "HTTP/1.1 200 OK\r\n")
+	assert.Contains(t, buf.String(), "Connection: close\r\n")
+	assert.NotContains(t, buf.String(), "Content-Length")
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("\r\n\r\nhi")))
+
+	// Test: Chunked body before status line and headers
+	buf.Reset()
+	w = NewWriter(&buf)
+	_, err = w.WriteChunkedBody([]byte("hi"))
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "Transfer-Encoding: chunked\r\n")
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("\r\n\r\n2\r\nhi\r\n")))
+}
+
+func TestWriterTrailers(t *testing.T) {
+	chunkedHeaders := func(trailer string) *headers.Headers {
+		h := headers.NewHeaders()
+		h.Set("Transfer-Encoding", "chunked")
+		if trailer != "" {
+			h.Set("Trailer", trailer)
+		}
+		return h
+	}
+
+	// Test: Declared trailers are written after the last chunk
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteHeaders(chunkedHeaders("X-Checksum, X-Length")))
+	_, err := w.WriteChunkedBody([]byte("hi"))
+	require.NoError(t, err)
+	require.NoError(t, w.WriteChunkedBodyDone())
+	trailer := headers.NewHeaders()
+	trailer.Set("X-Checksum", "abc")
+	trailer.Set("x-length", "2")
+	require.NoError(t, w.WriteTrailers(trailer))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"Trailer: X-Checksum, X-Length\r\n"+
+		"\r\n"+
+		"2\r\nhi\r\n"+
+		"0\r\n"+
+		"X-Checksum: abc\r\n"+
+		"X-Length: 2\r\n"+
+		"\r\n", buf.String())
+
+	// Test: Undeclared and forbidden trailers are rejected
+	w = NewWriter(&buf)
+	require.NoError(t, w.WriteHeaders(chunkedHeaders("X-Checksum")))
+	require.NoError(t, w.WriteChunkedBodyDone())
+	var headerErr *InvalidHeaderError
+	trailer = headers.NewHeaders()
+	trailer.Set("X-Other", "1")
+	require.ErrorAs(t, w.WriteTrailers(trailer), &headerErr)
+	assert.Equal(t, "X-Other", headerErr.Name)
+	trailer = headers.NewHeaders()
+	trailer.Set("Content-Length", "1")
+	require.ErrorAs(t, w.WriteTrailers(trailer), &headerErr)
+
+	// Test: Forbidden fields cannot be declared
+	w = NewWriter(&buf)
+	require.ErrorAs(t, w.WriteHeaders(chunkedHeaders("X-Checksum, Host")), &headerErr)
+
+	// Test: Finish terminates a response left open
+	buf.Reset()
+	w = NewWriter(&buf)
+	require.NoError(t, w.WriteHeaders(chunkedHeaders("X-Checksum")))
+	_, err = w.WriteChunkedBody([]byte("hi"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("2\r\nhi\r\n0\r\n\r\n")))
+	require.NoError(t, w.Finish())
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("2\r\nhi\r\n0\r\n\r\n")))
+}
+
+func TestWriterAutoFraming(t *testing.T) {
+	// Test: Small body gets a Content-Length
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Header().Set("Content-Type", "text/plain")
+	_, err := w.Write([]byte("hello "))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("world"))
+	require.NoError(t, err)
+	assert.Empty(t, buf.String())
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 11\r\n\r\nhello world", buf.String())
+
+	// Test: Custom status and default content type
+	buf.Reset()
+	w = NewWriter(&buf)
+	require.NoError(t, w.WriteStatusLine(StatusNotFound))
+	_, err = w.Write([]byte("<h1>nope</h1>"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 404 Not Found\r\nContent-Length: 13\r\nContent-Type: text/html\r\n\r\n<h1>nope</h1>", buf.String())
+
+	// Test: Nothing written at all
+	buf.Reset()
+	w = NewWriter(&buf)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n", buf.String())
+
+	// Test: Bodiless statuses get no Content-Length and no body
+	for _, code := range []StatusCode{StatusNoContent, StatusNotModified} {
+		buf.Reset()
+		w = NewWriter(&buf)
+		require.NoError(t, w.WriteStatusLine(code))
+		_, err = w.Write([]byte("ignored"))
+		require.NoError(t, err)
+		require.NoError(t, w.Finish())
+		assert.Equal(t, fmt.Sprintf("HTTP/1.1 %d %s\r\n\r\n", code, StatusText(code)), buf.String())
+	}
+	buf.Reset()
+	w = NewWriter(&buf)
+	require.NoError(t, w.WriteStatusLine(StatusNoContent))
+	require.NoError(t, w.Flush())
+	assert.NotContains(t, buf.String(), "Transfer-Encoding")
+
+	// Test: Flush switches to chunked encoding
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.Header().Set("Content-Type", "text/plain")
+	_, err = w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	_, err = w.Write([]byte("world"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n"+
+		"5\r\nhello\r\n"+
+		"5\r\nworld\r\n"+
+		"0\r\n\r\n", buf.String())
+
+	// Test: Overflowing the buffer switches to chunked encoding
+	buf.Reset()
+	w = NewWriter(&buf)
+	body := bytes.Repeat([]byte("a"), bufferSize+1)
+	_, err = w.Write(body)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "Transfer-Encoding: chunked\r\n")
+	require.NoError(t, w.Finish())
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("\r\n0\r\n\r\n")))
+
+	// Test: Explicit Content-Length streams a plain body
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	_, err = w.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.NotContains(t, buf.String(), "Transfer-Encoding")
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), append([]byte("\r\n\r\n"), body...)))
+
+	// Test: Trailers after buffered writes
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.Header().Set("Trailer", "X-Sum")
+	_, err = w.Write([]byte("hi"))
+	require.NoError(t, err)
+	require.NoError(t, w.WriteChunkedBodyDone())
+	trailer := headers.NewHeaders()
+	trailer.Set("X-Sum", "1")
+	require.NoError(t, w.WriteTrailers(trailer))
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("\r\n\r\n2\r\nhi\r\n0\r\nX-Sum: 1\r\n\r\n")))
+
+	// Test: Buffered body follows explicit headers
+	buf.Reset()
+	w = NewWriter(&buf)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(5)))
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\nContent-Type: text/html\r\n\r\nhello", buf.String())
+
+	// Test: Buffered body comes before an explicit body
+	buf.Reset()
+	w = NewWriter(&buf)
+	_, err = w.Write([]byte("hello "))
+	require.NoError(t, err)
+	_, err = w.WriteBody([]byte("world"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\n\r\nhello world"))
+	assert.True(t, w.ClosesConnection())
+}
+
+func TestWriterOmitBody(t *testing.T) {
+	// Test: Buffered body keeps its Content-Length
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.OmitBody()
+	_, err := w.Write([]byte("hello world"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 11\r\nContent-Type: text/html\r\n\r\n", buf.String())
+
+	// Test: Explicit plain body
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.OmitBody()
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(2)))
+	_, err = w.WriteBody([]byte("ok"))
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\nContent-Type: text/html\r\n\r\n", buf.String())
+
+	// Test: Chunked body and trailers
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.OmitBody()
+	w.Header().Set("Trailer", "X-Sum")
+	_, err = w.Write([]byte("hi"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	require.NoError(t, w.WriteChunkedBodyDone())
+	trailer := headers.NewHeaders()
+	trailer.Set("X-Sum", "1")
+	require.NoError(t, w.WriteTrailers(trailer))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nTrailer: X-Sum\r\nTransfer-Encoding: chunked\r\nContent-Type: text/html\r\n\r\n", buf.String())
+}
+
+func TestWriterInformational(t *testing.T) {
+	// Test: Early hints ahead of the final response
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.OmitBody()
+	hints := headers.NewHeaders()
+	hints.Add("Link", "</style.css>; rel=preload; as=style")
+	require.NoError(t, w.WriteInformational(StatusEarlyHints, hints))
+	require.NoError(t, w.WriteInformational(StatusContinue, nil))
+	require.NoError(t, w.WriteStatusLine(StatusOk))
+	assert.Equal(t, "HTTP/1.1 103 Early Hints\r\n"+
+		"Link: </style.css>; rel=preload; as=style\r\n"+
+		"\r\n"+
+		"HTTP/1.1 100 Continue\r\n"+
+		"\r\n"+
+		"HTTP/1.1 200 OK\r\n", buf.String())
+
+	// Test: Only before the final response and only 1xx
+	assert.ErrorIs(t, w.WriteInformational(StatusContinue, nil), ErrWriteOrder)
+	w = NewWriter(&buf)
+	assert.ErrorIs(t, w.WriteInformational(StatusOk, nil), ErrInvalidStatus)
+	assert.ErrorIs(t, w.WriteInformational(StatusSwitchingProtocols, nil), ErrInvalidStatus)
+}
+
+func TestWriterHTTP10(t *testing.T) {
+	// Test: Small body keeps its Content-Length and keep-alive is announced
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.UseHTTP10()
+	w.Header().Set("Content-Type", "text/plain")
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 5\r\nConnection: keep-alive\r\n\r\nhello", buf.String())
+	assert.False(t, w.ClosesConnection())
+
+	// Test: Flushed body is close-delimited instead of chunked
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.UseHTTP10()
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Trailer", "X-Sum")
+	_, err = w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	_, err = w.Write([]byte("world"))
+	require.NoError(t, err)
+	require.NoError(t, w.WriteChunkedBodyDone())
+	trailer := headers.NewHeaders()
+	trailer.Set("X-Sum", "1")
+	require.NoError(t, w.WriteTrailers(trailer))
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nConnection: close\r\n\r\nhelloworld", buf.String())
+	assert.True(t, w.ClosesConnection())
+
+	// Test: Interim responses are dropped
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.UseHTTP10()
+	w.CloseConnection()
+	require.NoError(t, w.WriteInformational(StatusContinue, nil))
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\nConnection: close\r\n\r\n", buf.String())
+}
@@ -0,0 +1,99 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanwiseman/httpfromtcp/internal/request"
+	"github.com/evanwiseman/httpfromtcp/internal/response"
+	"github.com/evanwiseman/httpfromtcp/internal/server"
+)
+
+// HostRouter dispatches requests to handlers by the host they are addressed
+// to, letting one server serve several virtual hosts.
+//
+// Patterns are host names without a port, such as "api.example.test", or
+// wildcards such as "*.example.test", which match any subdomain of
+// example.test but not example.test itself. Hosts are compared
+// case-insensitively and without the port. An exact match wins over
+// wildcards, and a longer wildcard wins over a shorter one. Requests for
+// other hosts go to the default handler, or get a 404 if there is none.
+type HostRouter struct {
+	hosts     map[string]server.Handler
+	wildcards []wildcard
+	fallback  server.Handler
+}
+
+type wildcard struct {
+	suffix  string // ".example.test" for "*.example.test"
+	handler server.Handler
+}
+
+func NewHostRouter() *HostRouter {
+	return &HostRouter{hosts: make(map[string]server.Handler)}
+}
+
+// Handle registers handler for the host pattern. It panics if the pattern is
+// invalid or already registered.
+func (hr *HostRouter) Handle(pattern string, handler server.Handler) {
+	host := strings.ToLower(pattern)
+	if host == "" || strings.ContainsAny(host, ":/ ") || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+		panic(fmt.Sprintf("router: invalid host pattern %q", pattern))
+	}
+
+	if suffix, ok := strings.CutPrefix(host, "*"); ok {
+		for _, w := range hr.wildcards {
+			if w.suffix == suffix {
+				panic(fmt.Sprintf("router: host pattern %q registered twice", pattern))
+			}
+		}
+		hr.wildcards = append(hr.wildcards, wildcard{suffix: suffix, handler: handler})
+		return
+	}
+	if _, ok := hr.hosts[host]; ok {
+		panic(fmt.Sprintf("router: host pattern %q registered twice", pattern))
+	}
+	hr.hosts[host] = handler
+}
+
+// Default sets the handler for requests whose host matches no pattern.
+func (hr *HostRouter) Default(handler server.Handler) {
+	hr.fallback = handler
+}
+
+// Handler dispatches req to the handler registered for its host.
+func (hr *HostRouter) Handler(w *response.Writer, req *request.Request) {
+	if handler := hr.match(hostname(req.Host())); handler != nil {
+		handler(w, req)
+		return
+	}
+	writeError(w, response.StatusNotFound, "")
+}
+
+func (hr *HostRouter) match(host string) server.Handler {
+	if handler, ok := hr.hosts[host]; ok {
+		return handler
+	}
+
+	var best *wildcard
+	for i, w := range hr.wildcards {
+		if len(host) <= len(w.suffix) || !strings.HasSuffix(host, w.suffix) {
+			continue
+		}
+		if best == nil || len(w.suffix) > len(best.suffix) {
+			best = &hr.wildcards[i]
+		}
+	}
+	if best != nil {
+		return best.handler
+	}
+	return hr.fallback
+}
+
+// hostname returns host in lower case without the port and a trailing dot.
+func hostname(host string) string {
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
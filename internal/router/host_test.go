@@ -0,0 +1,61 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveHost(t *testing.T, hr *HostRouter, host string) (*http.Response, string) {
+	t.Helper()
+	return do(t, hr.Handler, "GET / HTTP/1.1\r\nHost: "+host+"\r\n\r\n")
+}
+
+func TestHostRouter(t *testing.T) {
+	hr := NewHostRouter()
+	hr.Handle("api.example.test", named("api"))
+	hr.Handle("Static.Example.test", named("static"))
+	hr.Handle("*.example.test", named("wildcard"))
+	hr.Handle("*.eu.example.test", named("eu"))
+
+	// Test: Exact hosts, ignoring case, port and a trailing dot
+	_, body := serveHost(t, hr, "api.example.test")
+	assert.Equal(t, "api", body)
+	_, body = serveHost(t, hr, "STATIC.example.test:42069")
+	assert.Equal(t, "static", body)
+	_, body = serveHost(t, hr, "api.example.test.")
+	assert.Equal(t, "api", body)
+
+	// Test: Wildcard subdomains, longest suffix first
+	_, body = serveHost(t, hr, "blog.example.test")
+	assert.Equal(t, "wildcard", body)
+	_, body = serveHost(t, hr, "a.b.example.test")
+	assert.Equal(t, "wildcard", body)
+	_, body = serveHost(t, hr, "shop.eu.example.test")
+	assert.Equal(t, "eu", body)
+
+	// Test: Unknown host without a default
+	res, _ := serveHost(t, hr, "example.test")
+	assert.Equal(t, 404, res.StatusCode)
+	res, _ = serveHost(t, hr, "[::1]:8080")
+	assert.Equal(t, 404, res.StatusCode)
+
+	// Test: Unknown host with a default
+	hr.Default(named("default"))
+	_, body = serveHost(t, hr, "example.test")
+	assert.Equal(t, "default", body)
+	_, body = serveHost(t, hr, "")
+	assert.Equal(t, "default", body)
+
+	// Test: Absolute-form authority selects the host
+	_, body = do(t, hr.Handler, "GET http://api.example.test/ HTTP/1.1\r\nHost: other.test\r\n\r\n")
+	assert.Equal(t, "api", body)
+
+	// Test: Invalid patterns
+	assert.Panics(t, func() { hr.Handle("", named("bad")) })
+	assert.Panics(t, func() { hr.Handle("api.example.test:80", named("bad")) })
+	assert.Panics(t, func() { hr.Handle("api.*.test", named("bad")) })
+	assert.Panics(t, func() { hr.Handle("API.example.test", named("bad")) })
+	assert.Panics(t, func() { hr.Handle("*.example.test", named("bad")) })
+}
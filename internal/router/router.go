@@ -0,0 +1,145 @@
+package router
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/evanwiseman/httpfromtcp/internal/request"
+	"github.com/evanwiseman/httpfromtcp/internal/response"
+	"github.com/evanwiseman/httpfromtcp/internal/server"
+)
+
+// Router dispatches requests to handlers by method and path pattern.
+//
+// Patterns have the form "[METHOD ]/path", where a segment may be a literal,
+// a parameter "{name}" matching exactly one segment, or, as the last segment
+// only, a wildcard "{name...}" matching the rest of the path (possibly
+// empty). Without a method the route matches every method. Routes are tried
+// in the order they were registered; a GET route also serves HEAD.
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	method   string // empty for any method
+	segments []string
+	handler  server.Handler
+}
+
+func New() *Router {
+	return &Router{}
+}
+
+// Handle registers handler for pattern. It panics if the pattern is invalid.
+func (rt *Router) Handle(pattern string, handler server.Handler) {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		method, path = "", pattern
+	}
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "/") {
+		panic(fmt.Sprintf("router: pattern %q must start with /", pattern))
+	}
+
+	segments := splitPath(path)
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, "{") {
+			continue
+		}
+		if !strings.HasSuffix(seg, "}") || len(seg) < 3 {
+			panic(fmt.Sprintf("router: invalid parameter %q in pattern %q", seg, pattern))
+		}
+		if strings.HasSuffix(seg, "...}") && i != len(segments)-1 {
+			panic(fmt.Sprintf("router: wildcard %q must be the last segment in pattern %q", seg, pattern))
+		}
+	}
+
+	rt.routes = append(rt.routes, route{
+		method:   method,
+		segments: segments,
+		handler:  handler,
+	})
+}
+
+// Handler dispatches req to the first matching route, matching the decoded
+// path segments and ignoring the query. Requests whose path
+// matches no route get a 404; those whose path matches only routes for other
+// methods get a 405 with an Allow header.
+func (rt *Router) Handler(w *response.Writer, req *request.Request) {
+	segments := req.Target.Segments()
+
+	var allowed []string
+	for _, r := range rt.routes {
+		params, ok := r.match(segments)
+		if !ok {
+			continue
+		}
+		if !r.allows(req.RequestLine.Method) {
+			allowed = append(allowed, r.method)
+			if r.method == "GET" {
+				allowed = append(allowed, "HEAD")
+			}
+			continue
+		}
+		req.Params = params
+		r.handler(w, req)
+		return
+	}
+
+	if len(allowed) > 0 {
+		slices.Sort(allowed)
+		writeError(w, response.StatusMethodNotAllowed, strings.Join(slices.Compact(allowed), ", "))
+		return
+	}
+	writeError(w, response.StatusNotFound, "")
+}
+
+func (r route) allows(method string) bool {
+	return r.method == "" || r.method == method || r.method == "GET" && method == "HEAD"
+}
+
+// match reports whether the path segments match the route, returning the
+// captured parameters.
+func (r route) match(segments []string) (map[string]string, bool) {
+	params := make(map[string]string)
+	for i, seg := range r.segments {
+		if name, ok := strings.CutSuffix(seg, "...}"); ok {
+			params[strings.TrimPrefix(name, "{")] = strings.Join(segments[i:], "/")
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if strings.HasPrefix(seg, "{") {
+			params[seg[1:len(seg)-1]] = segments[i]
+			continue
+		}
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func writeError(w *response.Writer, statusCode response.StatusCode, allow string) {
+	body := []byte(fmt.Sprintf("<html>\n<body>\n<h1>%d</h1>\n</body>\n</html>", statusCode))
+	headers := response.GetDefaultHeaders(len(body))
+	if allow != "" {
+		headers.Set("Allow", allow)
+	}
+	w.WriteStatusLine(statusCode)
+	w.WriteHeaders(headers)
+	w.WriteBody(body)
+}
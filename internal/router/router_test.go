@@ -0,0 +1,104 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/evanwiseman/httpfromtcp/internal/request"
+	"github.com/evanwiseman/httpfromtcp/internal/response"
+	"github.com/evanwiseman/httpfromtcp/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func named(name string) func(w *response.Writer, req *request.Request) {
+	return func(w *response.Writer, req *request.Request) {
+		body := []byte(name)
+		for _, key := range []string{"id", "rest"} {
+			if value, ok := req.Params[key]; ok {
+				body = append(body, []byte(" "+key+"="+value)...)
+			}
+		}
+		w.WriteStatusLine(response.StatusOk)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody(body)
+	}
+}
+
+func serve(t *testing.T, rt *Router, method string, target string) (*http.Response, string) {
+	t.Helper()
+	return do(t, rt.Handler, method+" "+target+" HTTP/1.1\r\nHost: localhost\r\n\r\n")
+}
+
+// do parses the raw request and returns the response handler writes for it.
+func do(t *testing.T, handler server.Handler, raw string) (*http.Response, string) {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	handler(response.NewWriter(&buf), req)
+
+	res, err := http.ReadResponse(bufio.NewReader(&buf), nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res, string(body)
+}
+
+func TestRouter(t *testing.T) {
+	rt := New()
+	rt.Handle("GET /users", named("list"))
+	rt.Handle("POST /users", named("create"))
+	rt.Handle("GET /users/{id}", named("get"))
+	rt.Handle("DELETE /users/{id}", named("delete"))
+	rt.Handle("/files/{rest...}", named("files"))
+
+	// Test: Literal route
+	res, body := serve(t, rt, "GET", "/users")
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "list", body)
+
+	// Test: Method selects the route
+	_, body = serve(t, rt, "POST", "/users")
+	assert.Equal(t, "create", body)
+
+	// Test: Parameter capture ignoring the query
+	_, body = serve(t, rt, "GET", "/users/42?verbose=1")
+	assert.Equal(t, "get id=42", body)
+
+	// Test: Parameters are percent-decoded
+	_, body = serve(t, rt, "GET", "/users/jane%20doe")
+	assert.Equal(t, "get id=jane doe", body)
+	_, body = serve(t, rt, "GET", "/users/a%2Fb")
+	assert.Equal(t, "get id=a/b", body)
+
+	// Test: HEAD is served by GET routes
+	_, body = serve(t, rt, "HEAD", "/users/42")
+	assert.Equal(t, "get id=42", body)
+
+	// Test: Wildcard suffix for any method
+	_, body = serve(t, rt, "PUT", "/files/a/b/c.txt")
+	assert.Equal(t, "files rest=a/b/c.txt", body)
+	_, body = serve(t, rt, "GET", "/files")
+	assert.Equal(t, "files rest=", body)
+
+	// Test: Unknown path
+	res, _ = serve(t, rt, "GET", "/nope")
+	assert.Equal(t, 404, res.StatusCode)
+	res, _ = serve(t, rt, "GET", "/users/42/extra")
+	assert.Equal(t, 404, res.StatusCode)
+
+	// Test: Known path with the wrong method
+	res, _ = serve(t, rt, "PATCH", "/users/42")
+	assert.Equal(t, 405, res.StatusCode)
+	assert.Equal(t, "DELETE, GET, HEAD", res.Header.Get("Allow"))
+
+	// Test: Invalid patterns
+	assert.Panics(t, func() { rt.Handle("GET users", named("bad")) })
+	assert.Panics(t, func() { rt.Handle("GET /{rest...}/more", named("bad")) })
+	assert.Panics(t, func() { rt.Handle("GET /{}", named("bad")) })
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"time"
+
+	"github.com/evanwiseman/httpfromtcp/internal/request"
+	"github.com/evanwiseman/httpfromtcp/internal/response"
+)
+
+// Option configures optional Server behavior in Serve.
+type Option func(*Server)
+
+// WithStreamingBody makes the server hand requests to the handler as soon as
+// the request line and headers are parsed. Handlers read the body through
+// Request.BodyReader and Request.Body is left empty. Unless WithLimits is
+// given, request.DefaultStreamLimits apply, so the body size is unlimited and
+// handlers must bound what they read themselves.
+func WithStreamingBody() Option {
+	return func(s *Server) {
+		s.streamBody = true
+	}
+}
+
+// WithLimits sets the limits applied while parsing requests, replacing
+// request.DefaultLimits or, with WithStreamingBody, request.DefaultStreamLimits.
+func WithLimits(limits request.Limits) Option {
+	return func(s *Server) {
+		s.limits = &limits
+	}
+}
+
+// Timeouts bounds how long the server waits on a connection. A zero field
+// means no timeout.
+type Timeouts struct {
+	// ReadHeader is the time allowed to read the request line and headers,
+	// measured from the first byte of the request. If zero, Read is used.
+	ReadHeader time.Duration
+	// Read is the time allowed to read the entire request, body included,
+	// measured from the first byte of the request.
+	Read time.Duration
+	// Write is the time allowed to write the response, measured from the
+	// moment the request has been read.
+	Write time.Duration
+	// Idle is how long a persistent connection may wait for the next
+	// request before it is closed.
+	Idle time.Duration
+}
+
+// DefaultTimeouts are the timeouts used unless WithTimeouts is given.
+var DefaultTimeouts = Timeouts{
+	ReadHeader: 10 * time.Second,
+	Idle:       2 * time.Minute,
+}
+
+func (t Timeouts) headerTimeout() time.Duration {
+	if t.ReadHeader > 0 {
+		return t.ReadHeader
+	}
+	return t.Read
+}
+
+// WithTimeouts sets the connection timeouts, replacing DefaultTimeouts.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(s *Server) {
+		s.timeouts = timeouts
+	}
+}
+
+// PanicHandler is called with the recovered value, the stack trace and the
+// request whenever a Handler panics, for example to forward the panic to an
+// error tracker.
+type PanicHandler func(v any, stack []byte, req *request.Request)
+
+// WithPanicHandler sets a hook called for every panic recovered from a
+// Handler, after the panic has been logged.
+func WithPanicHandler(fn PanicHandler) Option {
+	return func(s *Server) {
+		s.onPanic = fn
+	}
+}
+
+// WithHeaderOrder sets the order response headers are serialized in. The
+// default is response.InsertionOrder.
+func WithHeaderOrder(order response.HeaderOrder) Option {
+	return func(s *Server) {
+		s.headerOrder = order
+	}
+}
+
+// WithErrorHandler replaces DefaultErrorHandler for the responses the server
+// writes itself.
+func WithErrorHandler(fn ErrorHandler) Option {
+	return func(s *Server) {
+		s.onError = fn
+	}
+}
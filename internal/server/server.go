@@ -1,10 +1,18 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"html"
+	"io"
 	"log"
 	"net"
+	"os"
+	"runtime/debug"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/evanwiseman/httpfromtcp/internal/request"
 	"github.com/evanwiseman/httpfromtcp/internal/response"
@@ -14,9 +22,25 @@ type Server struct {
 	listener net.Listener
 	handler  Handler
 	isOpen   atomic.Bool
+
+	// ctx is the parent of every request context and is cancelled once the
+	// server stops serving in-flight requests
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	conns map[net.Conn]connState
+
+	streamBody bool
+	limits     *request.Limits // nil for the defaults
+	timeouts   Timeouts
+	onPanic    PanicHandler
+	onError    ErrorHandler
+
+	headerOrder response.HeaderOrder
 }
 
-func Serve(port int, handler Handler) (*Server, error) {
+func Serve(port int, handler Handler, opts ...Option) (*Server, error) {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
 	if err != nil {
 		return nil, err
@@ -25,16 +49,35 @@ func Serve(port int, handler Handler) (*Server, error) {
 	server := &Server{
 		listener: listener,
 		handler:  handler,
+		timeouts: DefaultTimeouts,
+		onError:  DefaultErrorHandler,
+		conns:    make(map[net.Conn]connState),
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+	if server.limits == nil {
+		limits := request.DefaultLimits
+		if server.streamBody {
+			limits = request.DefaultStreamLimits
+		}
+		server.limits = &limits
 	}
+	server.ctx, server.cancel = context.WithCancel(context.Background())
 	server.isOpen.Store(true)
 	go server.listen()
 
 	return server, nil
 }
 
+// Close stops accepting connections and immediately closes every open
+// connection, including those with requests in flight, cancelling their
+// contexts. Use Shutdown to let in-flight requests finish.
 func (s *Server) Close() {
 	s.isOpen.Store(false)
+	s.cancel()
 	s.listener.Close()
+	s.closeConns()
 }
 
 func (s *Server) listen() {
@@ -47,24 +90,353 @@ func (s *Server) listen() {
 			log.Println("error accepting connection", err)
 			continue
 		}
+		if !s.trackConn(conn, connIdle) {
+			conn.Close()
+			continue
+		}
 		go s.handle(conn)
 	}
 }
 
+type connState int
+
+const (
+	connIdle   connState = iota // waiting for the next request
+	connActive                  // reading a request or writing its response
+)
+
+// trackConn records the state of conn. Connections that are not tracked yet,
+// and those Close has already dropped, are refused once the server is
+// closed, in which case it returns false.
+//
+// A connection only becomes active in its own goroutine, after the first
+// byte of a request has been read from it. Shutdown therefore never closes
+// idle connections itself, as it could not tell whether a request has just
+// arrived; it wakes them with wakeIdle and lets their goroutine decide.
+func (s *Server) trackConn(conn net.Conn, state connState) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, tracked := s.conns[conn]; !tracked && !s.isOpen.Load() {
+		return false
+	}
+	s.conns[conn] = state
+	return true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+// closeConns closes every tracked connection and returns how many it closed.
+func (s *Server) closeConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	closed := 0
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+		closed++
+	}
+	return closed
+}
+
+// wakeGrace is how long a connection woken by wakeIdle still waits for a
+// request. It must be shorter than shutdownPollInterval.
+const wakeGrace = 10 * time.Millisecond
+
+// wakeIdle interrupts the wait for the next request on idle connections. A
+// connection whose next request has already started arriving is served, the
+// others are closed by their goroutine. The deadline is set slightly in the
+// future rather than in the past, since a read past its deadline fails
+// without picking up bytes that are already waiting.
+func (s *Server) wakeIdle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, state := range s.conns {
+		if state == connIdle {
+			conn.SetReadDeadline(time.Now().Add(wakeGrace))
+		}
+	}
+}
+
 func (s *Server) handle(conn net.Conn) {
-	defer conn.Close()
+	responded := false
+	defer func() {
+		s.untrackConn(conn)
+		if responded {
+			lingerClose(conn)
+		} else {
+			conn.Close()
+		}
+	}()
 
-	req, err := request.RequestFromReader(conn)
-	w := response.NewWriter(conn)
-	if err != nil {
-		w.WriteStatusLine(response.StatusBadRequest)
-		body := []byte(fmt.Sprintf("error parsing request: %v", err))
-		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
-		w.WriteBody(body)
-		return
+	// Requests are read one after another from the same reader, so bytes of
+	// a pipelined request read along with the previous one are not lost.
+	// Responses are written in request order as each is served in turn.
+	reader := request.NewReader(conn)
+	for {
+		if !s.trackConn(conn, connIdle) {
+			return
+		}
+		// Wait for the first byte of the next request, giving up once the
+		// connection has been idle for too long, the client hung up or the
+		// server is shutting down. Once a byte has arrived, the request is
+		// served even if the server is shutting down.
+		conn.SetReadDeadline(deadline(time.Now(), s.timeouts.Idle))
+		if _, err := reader.Peek(1); err != nil {
+			return
+		}
+		s.trackConn(conn, connActive)
+		responded = true
+
+		start := time.Now()
+		conn.SetReadDeadline(deadline(start, s.timeouts.headerTimeout()))
+		conn.SetWriteDeadline(time.Time{})
+		w := response.NewWriter(conn)
+		w.SetHeaderOrder(s.headerOrder)
+		req, err := request.StreamRequestFromReaderWithLimits(reader, *s.limits)
+		if err == nil {
+			conn.SetReadDeadline(deadline(start, s.timeouts.Read))
+			if req.IsHTTP10() {
+				w.UseHTTP10()
+			}
+			if req.ExpectsContinue() {
+				req.BodyReader = &continueReader{ReadCloser: req.BodyReader, w: w}
+			}
+			if !s.streamBody {
+				err = req.ReadBody()
+			}
+		}
+
+		conn.SetWriteDeadline(deadline(time.Now(), s.timeouts.Write))
+		if err != nil {
+			s.writeError(w, err)
+			return
+		}
+
+		if req.RequestLine.Method == "HEAD" {
+			w.OmitBody()
+		}
+		if !req.KeepAlive() || !s.isOpen.Load() {
+			w.CloseConnection()
+		}
+
+		ctx, cancel := s.requestContext()
+		req.SetContext(ctx)
+		stopWatch := func() {}
+		if req.State == request.ParserDone {
+			stopWatch = watchConn(conn, reader, cancel)
+		}
+		panicked := s.serveRequest(w, req)
+		stopWatch()
+		cancel()
+		if panicked {
+			return
+		}
+		w.Finish()
+		if w.ClosesConnection() {
+			return
+		}
+
+		// A streamed body the handler did not finish leaves the connection
+		// at an unknown position, so it cannot be reused
+		if req.State != request.ParserDone {
+			return
+		}
 	}
+}
+
+// requestContext returns the context for a request, which ends with the
+// write deadline of its response.
+func (s *Server) requestContext() (context.Context, context.CancelFunc) {
+	if s.timeouts.Write > 0 {
+		return context.WithTimeout(s.ctx, s.timeouts.Write)
+	}
+	return context.WithCancel(s.ctx)
+}
+
+// watchConn cancels a request's context if the client hangs up while the
+// handler runs, by waiting for the next byte on the connection. Bytes of a
+// pipelined request are kept in reader for the next iteration. It may only
+// run once the request body has been read, since the handler reads the body
+// from the same connection. The returned function stops the watch and must be
+// called before reading from the connection again.
+func watchConn(conn net.Conn, reader *request.Reader, cancel context.CancelFunc) (stop func()) {
+	conn.SetReadDeadline(time.Time{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := reader.Peek(1); err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+			cancel()
+		}
+	}()
+	return func() {
+		// A deadline in the past unblocks the pending read
+		conn.SetReadDeadline(time.Unix(1, 0))
+		<-done
+	}
+}
+
+// lingerTimeout and lingerBytes bound how long and how much lingerClose drains
+// from a connection before closing it.
+const (
+	lingerTimeout = 500 * time.Millisecond
+	lingerBytes   = 256 << 10
+)
+
+// lingerClose closes conn after the last response. Closing a socket with unread
+// data, such as pipelined requests that will not be answered, makes the kernel
+// reset the connection, which can destroy the response before the client has
+// read it. The write side is shut down first instead, and what the client
+// still sends is drained for a moment to let it see the end of the response.
+func lingerClose(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok && cw.CloseWrite() == nil {
+		conn.SetReadDeadline(time.Now().Add(lingerTimeout))
+		io.Copy(io.Discard, io.LimitReader(conn, lingerBytes))
+	}
+	conn.Close()
+}
+
+// serveRequest runs the handler for req, recovering from a panic in it with
+// HandlePanic and passing the panic to the panic hook. It reports whether the
+// handler panicked, in which case the connection must be closed.
+func (s *Server) serveRequest(w *response.Writer, req *request.Request) (panicked bool) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		panicked = true
+
+		started := w.StatusCode() != 0
+		w.CloseConnection()
+		stack := debug.Stack()
+		HandlePanic(w, req, v, stack, s.onError)
+		if s.onPanic != nil {
+			s.onPanic(v, stack, req)
+		}
+		if !started {
+			w.Finish()
+		}
+	}()
 
 	s.handler(w, req)
+	return false
+}
+
+// HandlePanic deals with a panic v recovered from the handler for req. The
+// panic is logged with the request line and stack. If the status line has
+// not been written yet, anything the handler buffered is dropped and a 500 is
+// written with onError; otherwise the connection is marked to be closed,
+// since the response cannot be completed.
+func HandlePanic(w *response.Writer, req *request.Request, v any, stack []byte, onError ErrorHandler) {
+	log.Printf("panic serving %s %s: %v\n%s", req.RequestLine.Method, req.RequestLine.RequestTarget, v, stack)
+	if w.StatusCode() != 0 {
+		w.CloseConnection()
+		return
+	}
+	w.Reset()
+	onError(w, response.StatusInternalServerError, "internal server error")
+}
+
+// continueReader sends a 100 Continue interim response the first time the
+// body is read, telling a client that sent "Expect: 100-continue" to go ahead
+// with the body. Nothing is sent if the final response has already started.
+type continueReader struct {
+	io.ReadCloser
+	w    *response.Writer
+	sent bool
+}
+
+func (c *continueReader) Read(p []byte) (int, error) {
+	if !c.sent {
+		c.sent = true
+		if c.w.StatusCode() == 0 {
+			if err := c.w.WriteInformational(response.StatusContinue, nil); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return c.ReadCloser.Read(p)
+}
+
+// writeError answers a request that could not be read and marks the
+// connection to be closed.
+func (s *Server) writeError(w *response.Writer, err error) {
+	statusCode, message := errorStatus(err)
+	w.CloseConnection()
+	s.onError(w, statusCode, message)
+	w.Finish()
+}
+
+// deadline returns the time timeout after start, or the zero time (no
+// deadline) if timeout is not positive.
+func deadline(start time.Time, timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return start.Add(timeout)
+}
+
+// statusError is implemented by the typed parse errors of the request and
+// headers packages.
+type statusError interface {
+	error
+	StatusCode() int
+}
+
+// errorStatus picks the response status for a request that failed to parse,
+// along with a message that is safe to send to the client.
+func errorStatus(err error) (response.StatusCode, string) {
+	var se statusError
+	switch {
+	case errors.As(err, &se):
+		return response.StatusCode(se.StatusCode()), se.Error()
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return response.StatusRequestTimeout, "request timed out"
+	default:
+		return response.StatusBadRequest, response.StatusText(response.StatusBadRequest)
+	}
+}
+
+// ErrorHandler writes the response for a request the server rejects itself,
+// such as one that failed to parse or whose handler panicked. message is a
+// short description that is safe to send to the client. The connection is
+// closed after the response.
+type ErrorHandler func(w *response.Writer, statusCode response.StatusCode, message string)
+
+// DefaultErrorHandler writes a small HTML page with the status and message.
+func DefaultErrorHandler(w *response.Writer, statusCode response.StatusCode, message string) {
+	w.WriteStatusLine(statusCode)
+	fmt.Fprintf(w, `<html>
+<head>
+<title>%[1]d %[2]s</title>
+</head>
+<body>
+<h1>%[2]s</h1>
+<p>%[3]s</p>
+</body>
+</html>`, statusCode, response.StatusText(statusCode), html.EscapeString(message))
 }
 
 type Handler func(w *response.Writer, req *request.Request)
+
+// Middleware wraps a Handler with behavior that runs around it.
+type Middleware func(Handler) Handler
+
+// Chain composes middlewares into one, with the first middleware outermost.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(h Handler) Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			h = middlewares[i](h)
+		}
+		return h
+	}
+}
+
+// Wrap applies middlewares to h, with the first middleware outermost.
+func Wrap(h Handler, middlewares ...Middleware) Handler {
+	return Chain(middlewares...)(h)
+}
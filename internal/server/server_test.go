@@ -0,0 +1,548 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evanwiseman/httpfromtcp/internal/request"
+	"github.com/evanwiseman/httpfromtcp/internal/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func okHandler(w *response.Writer, _ *request.Request) {
+	body := []byte("ok")
+	w.WriteStatusLine(response.StatusOk)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
+
+func startServer(t *testing.T, handler Handler) (*Server, net.Conn) {
+	t.Helper()
+	s, err := Serve(0, handler)
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return s, conn
+}
+
+func readResponse(t *testing.T, reader *bufio.Reader) (*http.Response, string) {
+	t.Helper()
+	res, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	res.Body.Close()
+	return res, string(body)
+}
+
+func TestKeepAlive(t *testing.T) {
+	// Test: Multiple requests on one connection
+	_, conn := startServer(t, okHandler)
+	reader := bufio.NewReader(conn)
+	for range 3 {
+		_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		require.NoError(t, err)
+		res, body := readResponse(t, reader)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "ok", body)
+		assert.False(t, res.Close)
+	}
+
+	// Test: Client asks to close the connection
+	_, conn = startServer(t, okHandler)
+	reader = bufio.NewReader(conn)
+	_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+	res, _ := readResponse(t, reader)
+	assert.True(t, res.Close)
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestStreamingBody(t *testing.T) {
+	echo := func(w *response.Writer, r *request.Request) {
+		body, err := io.ReadAll(r.BodyReader)
+		assert.NoError(t, err)
+		w.WriteStatusLine(response.StatusOk)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody(body)
+	}
+	s, err := Serve(0, echo, WithStreamingBody())
+	require.NoError(t, err)
+	defer s.Close()
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Test: Body is streamed to the handler and the connection is reused
+	reader := bufio.NewReader(conn)
+	for _, body := range []string{"hello", "world!"} {
+		_, err := conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n"))
+		require.NoError(t, err)
+		_, err = conn.Write([]byte(fmt.Sprintf("%x\r\n%s\r\n0\r\n\r\n", len(body), body)))
+		require.NoError(t, err)
+		res, got := readResponse(t, reader)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, body, got)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	limits := request.Limits{
+		MaxRequestLineBytes: 32,
+		MaxHeaderBytes:      64,
+		MaxHeaderCount:      2,
+		MaxBodyBytes:        8,
+	}
+	s, err := Serve(0, okHandler, WithLimits(limits))
+	require.NoError(t, err)
+	defer s.Close()
+
+	tests := []struct {
+		name   string
+		data   string
+		status int
+	}{
+		{"request-line", "GET /" + strings.Repeat("a", 64) + " HTTP/1.1\r\n\r\n", 414},
+		{"header count", "GET / HTTP/1.1\r\nA: 1\r\nB: 2\r\nC: 3\r\n\r\n", 431},
+		{"body", "POST / HTTP/1.1\r\nContent-Length: 100\r\n\r\n", 413},
+	}
+	for _, tt := range tests {
+		conn, err := net.Dial("tcp", s.listener.Addr().String())
+		require.NoError(t, err)
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		_, err = conn.Write([]byte(tt.data))
+		require.NoError(t, err)
+		res, _ := readResponse(t, bufio.NewReader(conn))
+		assert.Equal(t, tt.status, res.StatusCode, tt.name)
+		conn.Close()
+	}
+}
+
+func TestStreamingLimits(t *testing.T) {
+	// Test: Streamed uploads are not capped by the default body limit
+	size := request.DefaultLimits.MaxBodyBytes + 1
+	s, err := Serve(0, func(w *response.Writer, req *request.Request) {
+		n, _ := io.Copy(io.Discard, req.BodyReader)
+		fmt.Fprint(w, n)
+	}, WithStreamingBody())
+	require.NoError(t, err)
+	defer s.Close()
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	go func() {
+		fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: %d\r\n\r\n", size)
+		conn.Write(make([]byte, size))
+	}()
+	res, body := readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, fmt.Sprint(size), body)
+}
+
+func TestTimeouts(t *testing.T) {
+	timeouts := Timeouts{
+		ReadHeader: 100 * time.Millisecond,
+		Idle:       100 * time.Millisecond,
+	}
+	s, err := Serve(0, okHandler, WithTimeouts(timeouts))
+	require.NoError(t, err)
+	defer s.Close()
+
+	// Test: Headers trickle in too slowly
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: loc"))
+	require.NoError(t, err)
+	res, _ := readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, 408, res.StatusCode)
+	assert.True(t, res.Close)
+
+	// Test: Idle connection is closed without a response
+	conn, err = net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = bufio.NewReader(conn).ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestShutdown(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	slow := func(w *response.Writer, r *request.Request) {
+		started <- struct{}{}
+		<-release
+		okHandler(w, r)
+	}
+
+	// Test: In-flight request finishes and idle connections are closed
+	s, conn := startServer(t, slow)
+	idle, err := net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	defer idle.Close()
+	idle.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	<-started
+
+	done := make(chan int)
+	go func() {
+		cut, err := s.Shutdown(context.Background())
+		assert.NoError(t, err)
+		done <- cut
+	}()
+	_, err = bufio.NewReader(idle).ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	close(release)
+	reader := bufio.NewReader(conn)
+	_, body := readResponse(t, reader)
+	assert.Equal(t, "ok", body)
+	assert.Equal(t, 0, <-done)
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Request on a freshly accepted connection is served
+	s, conn = startServer(t, okHandler)
+	require.Eventually(t, func() bool { return s.numConns() == 1 }, time.Second, time.Millisecond)
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	_, err = s.Shutdown(context.Background())
+	require.NoError(t, err)
+	res, body := readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "ok", body)
+	assert.True(t, res.Close)
+
+	// Test: Context expires while a request is in flight
+	release = make(chan struct{})
+	defer close(release)
+	s, conn = startServer(t, slow)
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	cut, err := s.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, cut)
+	_, err = bufio.NewReader(conn).ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestPanicRecovery(t *testing.T) {
+	panics := make(chan any, 2)
+	hook := func(v any, stack []byte, req *request.Request) {
+		assert.NotEmpty(t, stack)
+		assert.Equal(t, "/boom", req.RequestLine.RequestTarget)
+		panics <- v
+	}
+	handler := func(w *response.Writer, r *request.Request) {
+		if r.RequestLine.Method == "POST" {
+			okHandler(w, r)
+		}
+		panic("boom")
+	}
+	s, err := Serve(0, handler, WithPanicHandler(hook))
+	require.NoError(t, err)
+	defer s.Close()
+
+	// Test: Panic before the status line is answered with a 500
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write([]byte("GET /boom HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	res, _ := readResponse(t, reader)
+	assert.Equal(t, 500, res.StatusCode)
+	assert.True(t, res.Close)
+	assert.Equal(t, "boom", <-panics)
+
+	// Test: Panic after the response started aborts the connection
+	conn, err = net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write([]byte("POST /boom HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	reader = bufio.NewReader(conn)
+	res, body := readResponse(t, reader)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "ok", body)
+	assert.Equal(t, "boom", <-panics)
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestHead(t *testing.T) {
+	handler := func(w *response.Writer, _ *request.Request) {
+		w.Write([]byte("hello world"))
+	}
+
+	// Test: HEAD gets the GET headers without a body
+	_, conn := startServer(t, handler)
+	reader := bufio.NewReader(conn)
+	_, err := conn.Write([]byte("HEAD / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	res, err := http.ReadResponse(reader, &http.Request{Method: "HEAD"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), res.ContentLength)
+
+	// Test: The connection stays usable after the bodiless response
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	_, body := readResponse(t, reader)
+	assert.Equal(t, "hello world", body)
+}
+
+func TestExpectContinue(t *testing.T) {
+	echo := func(w *response.Writer, r *request.Request) {
+		body, err := io.ReadAll(r.BodyReader)
+		assert.NoError(t, err)
+		w.Write(body)
+	}
+	limits := request.DefaultLimits
+	limits.MaxBodyBytes = 16
+
+	for _, opts := range [][]Option{{WithLimits(limits)}, {WithLimits(limits), WithStreamingBody()}} {
+		s, err := Serve(0, echo, opts...)
+		require.NoError(t, err)
+
+		// Test: 100 Continue is sent before the body is read
+		conn, err := net.Dial("tcp", s.listener.Addr().String())
+		require.NoError(t, err)
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		reader := bufio.NewReader(conn)
+		_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\nExpect: 100-continue\r\n\r\n"))
+		require.NoError(t, err)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/1.1 100 Continue\r\n", line)
+		line, err = reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "\r\n", line)
+		_, err = conn.Write([]byte("hello"))
+		require.NoError(t, err)
+		res, body := readResponse(t, reader)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "hello", body)
+
+		// Test: Oversized body is rejected without 100 Continue
+		_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 100\r\nExpect: 100-continue\r\n\r\n"))
+		require.NoError(t, err)
+		res, _ = readResponse(t, reader)
+		assert.Equal(t, 413, res.StatusCode)
+		conn.Close()
+
+		// Test: Unknown expectation
+		conn, err = net.Dial("tcp", s.listener.Addr().String())
+		require.NoError(t, err)
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\nExpect: teapot\r\n\r\n"))
+		require.NoError(t, err)
+		res, _ = readResponse(t, bufio.NewReader(conn))
+		assert.Equal(t, 417, res.StatusCode)
+		conn.Close()
+		s.Close()
+	}
+}
+
+func TestHTTP10(t *testing.T) {
+	// Test: HTTP/1.0 connection is closed after the response
+	_, conn := startServer(t, okHandler)
+	reader := bufio.NewReader(conn)
+	_, err := conn.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+	require.NoError(t, err)
+	res, body := readResponse(t, reader)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "ok", body)
+	assert.True(t, res.Close)
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: HTTP/1.0 keep-alive is honored
+	_, conn = startServer(t, okHandler)
+	reader = bufio.NewReader(conn)
+	for range 2 {
+		_, err = conn.Write([]byte("GET / HTTP/1.0\r\nConnection: keep-alive\r\n\r\n"))
+		require.NoError(t, err)
+		res, _ = readResponse(t, reader)
+		assert.Equal(t, "keep-alive", res.Header.Get("Connection"))
+	}
+
+	// Test: Streamed body is close-delimited instead of chunked
+	streaming := func(w *response.Writer, _ *request.Request) {
+		w.Write([]byte("hello "))
+		w.Flush()
+		w.Write([]byte("world"))
+	}
+	_, conn = startServer(t, streaming)
+	_, err = conn.Write([]byte("GET / HTTP/1.0\r\nConnection: keep-alive\r\n\r\n"))
+	require.NoError(t, err)
+	raw, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "Transfer-Encoding")
+	assert.True(t, strings.HasSuffix(string(raw), "\r\n\r\nhello world"))
+}
+
+func TestPipelining(t *testing.T) {
+	echo := func(w *response.Writer, req *request.Request) {
+		w.Write([]byte(req.RequestLine.RequestTarget + " " + string(req.Body)))
+	}
+
+	// Test: Requests sent in one write are answered in order
+	_, conn := startServer(t, echo)
+	reader := bufio.NewReader(conn)
+	_, err := conn.Write([]byte("POST /first HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\n\r\nhello" +
+		"POST /second HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nworld\r\n0\r\n\r\n" +
+		"GET /third HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	for _, want := range []string{"/first hello", "/second world", "/third "} {
+		res, body := readResponse(t, reader)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, want, body)
+	}
+
+	// Test: Pipelined requests after Connection: close are dropped
+	_, conn = startServer(t, echo)
+	reader = bufio.NewReader(conn)
+	_, err = conn.Write([]byte("GET /first HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n" +
+		"GET /second HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	_, body := readResponse(t, reader)
+	assert.Equal(t, "/first ", body)
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestRequestContext(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	waiting := func(w *response.Writer, req *request.Request) {
+		close(started)
+		<-req.Context().Done()
+		cancelled <- req.Context().Err()
+	}
+
+	// Test: Client disconnect cancels the context
+	_, conn := startServer(t, waiting)
+	_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	<-started
+	conn.Close()
+	select {
+	case err := <-cancelled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(2 * time.Second):
+		t.Fatal("context not cancelled after client disconnect")
+	}
+
+	// Test: Closing the server cancels the context
+	started = make(chan struct{})
+	s, conn := startServer(t, waiting)
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	<-started
+	s.Close()
+	select {
+	case err := <-cancelled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(2 * time.Second):
+		t.Fatal("context not cancelled after server close")
+	}
+
+	// Test: Write timeout ends the context
+	s, err = Serve(0, func(w *response.Writer, req *request.Request) {
+		<-req.Context().Done()
+		cancelled <- req.Context().Err()
+	}, WithTimeouts(Timeouts{Write: 50 * time.Millisecond}))
+	require.NoError(t, err)
+	defer s.Close()
+	conn, err = net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	select {
+	case err := <-cancelled:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(2 * time.Second):
+		t.Fatal("context not cancelled after write timeout")
+	}
+
+	// Test: Pipelined request is not mistaken for a disconnect
+	_, conn = startServer(t, func(w *response.Writer, req *request.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(fmt.Sprint(req.Context().Err())))
+	})
+	reader := bufio.NewReader(conn)
+	_, err = conn.Write([]byte("GET /a HTTP/1.1\r\nHost: localhost\r\n\r\nGET /b HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	for range 2 {
+		_, body := readResponse(t, reader)
+		assert.Equal(t, "<nil>", body)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var messages []string
+	onError := func(w *response.Writer, statusCode response.StatusCode, message string) {
+		messages = append(messages, message)
+		DefaultErrorHandler(w, statusCode, message)
+	}
+	s, err := Serve(0, okHandler, WithErrorHandler(onError))
+	require.NoError(t, err)
+	defer s.Close()
+
+	tests := []struct {
+		data    string
+		status  int
+		message string
+	}{
+		{"GET /<script> HTTP/1.1 extra\r\n\r\n", 400, "malformed request-line"},
+		{"get / HTTP/1.1\r\n\r\n", 400, "invalid method"},
+		{"GET / HTTP/2.0\r\n\r\n", 505, "HTTP version not supported"},
+		{"GET / HTTP/1.1\r\nHost : x\r\n\r\n", 400, "malformed header field"},
+		{"GET / HTTP/1.1\r\nH©st: x\r\n\r\n", 400, "invalid header field name"},
+		{"POST / HTTP/1.1\r\nContent-Length: abc\r\n\r\n", 400, "invalid content-length"},
+		{"POST / HTTP/1.1\r\nContent-Length: 3\r\nTransfer-Encoding: chunked\r\n\r\n", 400, "ambiguous message framing"},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\n\r\n", 501, "transfer coding not implemented"},
+		{"GET / HTTP/1.1\r\n\r\n", 400, "invalid host"},
+		{"GET / HTTP/1.1\r\nHost: a.test\r\nHost: b.test\r\n\r\n", 400, "invalid host"},
+	}
+	for _, tt := range tests {
+		// Test: Status and message come from the typed error, without details
+		conn, err := net.Dial("tcp", s.listener.Addr().String())
+		require.NoError(t, err)
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		_, err = conn.Write([]byte(tt.data))
+		require.NoError(t, err)
+		res, body := readResponse(t, bufio.NewReader(conn))
+		conn.Close()
+		assert.Equal(t, tt.status, res.StatusCode, tt.data)
+		assert.Contains(t, body, tt.message)
+		assert.NotContains(t, body, "<script>")
+		assert.Equal(t, tt.message, messages[len(messages)-1])
+	}
+}
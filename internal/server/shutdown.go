@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// shutdownPollInterval is how often Shutdown checks for connections that
+// have gone idle.
+const shutdownPollInterval = 50 * time.Millisecond
+
+// Shutdown gracefully stops the server. It stops accepting connections,
+// closes idle keep-alive connections and waits for active requests to finish,
+// closing connections as they go idle. If ctx expires first, the contexts of
+// the remaining requests are cancelled, their connections are closed forcibly
+// and Shutdown returns how many were cut along with the context's error.
+func (s *Server) Shutdown(ctx context.Context) (int, error) {
+	s.isOpen.Store(false)
+	s.listener.Close()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		s.wakeIdle()
+		if s.numConns() == 0 {
+			return 0, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			s.cancel()
+			return s.closeConns(), ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) numConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}